@@ -0,0 +1,190 @@
+// Package aesgcmembedder implements an asset embedder that encrypts
+// assets using AES-GCM, then encodes the resulting data as base64
+// strings.
+//
+// Each asset is sealed with a nonce, which precedes it.  The nonce is
+// derived from the key and the contents of the asset, using
+// HMAC-SHA256, so that encrypting the same assets with the same key
+// generates the same Go source file.  Only assets with identical
+// contents share a nonce, so it is never reused for different
+// plaintexts, but whether two assets are identical is not hidden.  The
+// key is not embedded: the loading function either takes it as a
+// parameter, or reads it, hex-encoded, from an environment variable.
+// Decrypting an asset with a wrong key fails, rather than returning
+// garbage.  At run time, the generated code only relies on crypto/aes
+// and crypto/cipher for decryption.
+package aesgcmembedder
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/jeanfric/goembed"
+)
+
+var (
+	imports    = [...]string{"crypto/aes", "crypto/cipher", "encoding/base64", "errors"}
+	envImports = [...]string{"crypto/aes", "crypto/cipher", "encoding/base64", "encoding/hex", "errors", "os"}
+)
+
+const (
+	// The prelude builds the AEAD once per call of the loading
+	// function, from the key, which is either a parameter of the
+	// loading function, or read by the prelude from the
+	// environment.  Its error is reported when decoding each
+	// asset.
+	prelude = `gcm, keyErr := func() (cipher.AEAD, error) {%s
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		return cipher.NewGCM(block)
+	}()`
+
+	envKey = `
+		key, err := hex.DecodeString(os.Getenv(%q))
+		if err != nil || len(key) == 0 {
+			return nil, errors.New(%q)
+		}`
+
+	decode = `func(s string) (string, error) {
+		if keyErr != nil {
+			return "", keyErr
+		}
+		b, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return "", err
+		}
+		if len(b) < gcm.NonceSize() {
+			return "", errors.New("missing nonce")
+		}
+		ob, err := gcm.Open(nil, b[:gcm.NonceSize()], b[gcm.NonceSize():], nil)
+		if err != nil {
+			return "", errors.New("decryption failed: wrong key or corrupted data")
+		}
+		return string(ob), nil
+	}`
+
+	params = "key []byte"
+)
+
+// ParseKey decodes a hex-encoded AES key, such as read from a key file
+// or an environment variable, ignoring the white space around it.  The
+// key must be 16, 24 or 32 bytes long, to select AES-128, AES-192 or
+// AES-256.
+func ParseKey(s string) ([]byte, error) {
+	key, err := hex.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return nil, fmt.Errorf("aesgcmembedder: invalid hex-encoded key: %v", err)
+	}
+	if _, err := aes.NewCipher(key); err != nil {
+		return nil, fmt.Errorf("aesgcmembedder: %v", err)
+	}
+	return key, nil
+}
+
+// encoder returns an encoding function that encrypts assets using
+// key, with nonces derived from their contents.
+func encoder(key []byte) (func(io.Reader) (string, error), error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("aesgcmembedder: %v", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return func(contents io.Reader) (string, error) {
+		b, err := ioutil.ReadAll(contents)
+		if err != nil {
+			return "", err
+		}
+		mac := hmac.New(sha256.New, key)
+		mac.Write(b)
+		nonce := mac.Sum(nil)[:gcm.NonceSize()]
+		return "`" + base64.StdEncoding.EncodeToString(gcm.Seal(nonce, nonce, b, nil)) + "`", nil
+	}, nil
+}
+
+// prepare returns a PrepareFunc declaring the AEAD built from the
+// key, read from the environment variable env if not empty, and
+// encrypting the assets using encode.
+func prepare(encode func(io.Reader) (string, error), env string) goembed.PrepareFunc {
+	p := fmt.Sprintf(prelude, "")
+	if env != "" {
+		p = fmt.Sprintf(prelude, fmt.Sprintf(envKey, env, "missing or invalid hex-encoded key in $"+env))
+	}
+	return func([][]byte) (string, func(io.Reader) (string, error), error) {
+		return p, encode, nil
+	}
+}
+
+// NewSequential creates a new sequential aesgcmembedder asset
+// embedder, encrypting assets using key, which must be 16, 24 or 32
+// bytes long.  The loading function takes the key as a parameter:
+//
+//	func loadAssets(key []byte) (map[string]string, error)
+func NewSequential(key []byte) (goembed.AssetEmbedder, error) {
+	encode, err := encoder(key)
+	if err != nil {
+		return nil, err
+	}
+	e := goembed.NewSequentialEmbedder(nil, decode, imports[:])
+	e.SetName("aesgcm")
+	e.SetParams(params)
+	e.SetPrepare(prepare(encode, ""))
+	return e, nil
+}
+
+// NewConcurrent creates a new concurrent aesgcmembedder asset
+// embedder, encrypting assets using key, which must be 16, 24 or 32
+// bytes long.  The loading function takes the key as a parameter.
+func NewConcurrent(key []byte) (goembed.AssetEmbedder, error) {
+	encode, err := encoder(key)
+	if err != nil {
+		return nil, err
+	}
+	e := goembed.NewConcurrentEmbedder(nil, decode, imports[:])
+	e.SetName("aesgcm")
+	e.SetParams(params)
+	e.SetPrepare(prepare(encode, ""))
+	return e, nil
+}
+
+// NewSequentialEnv creates a new sequential aesgcmembedder asset
+// embedder, encrypting assets using key, which must be 16, 24 or 32
+// bytes long.  The loading function reads the key, hex-encoded, from
+// the environment variable env, and fails if it is not set.
+func NewSequentialEnv(key []byte, env string) (goembed.AssetEmbedder, error) {
+	encode, err := encoder(key)
+	if err != nil {
+		return nil, err
+	}
+	e := goembed.NewSequentialEmbedder(nil, decode, envImports[:])
+	e.SetName("aesgcm")
+	e.SetPrepare(prepare(encode, env))
+	return e, nil
+}
+
+// NewConcurrentEnv creates a new concurrent aesgcmembedder asset
+// embedder, encrypting assets using key, which must be 16, 24 or 32
+// bytes long.  The loading function reads the key, hex-encoded, from
+// the environment variable env, and fails if it is not set.
+func NewConcurrentEnv(key []byte, env string) (goembed.AssetEmbedder, error) {
+	encode, err := encoder(key)
+	if err != nil {
+		return nil, err
+	}
+	e := goembed.NewConcurrentEmbedder(nil, decode, envImports[:])
+	e.SetName("aesgcm")
+	e.SetPrepare(prepare(encode, env))
+	return e, nil
+}
@@ -0,0 +1,127 @@
+package aesgcmembedder
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/jeanfric/goembed"
+	"github.com/jeanfric/goembed/embedtesting"
+)
+
+var (
+	testKey  = []byte("0123456789abcdef0123456789abcdef")
+	wrongKey = []byte("fedcba9876543210fedcba9876543210")
+)
+
+const testEnv = "GOEMBED_TEST_KEY"
+
+func newSequential(b testing.TB) goembed.AssetEmbedder {
+	ae, err := NewSequential(testKey)
+	if err != nil {
+		b.Fatal(err)
+	}
+	return ae
+}
+
+func newConcurrentEnv(b testing.TB) goembed.AssetEmbedder {
+	ae, err := NewConcurrentEnv(testKey, testEnv)
+	if err != nil {
+		b.Fatal(err)
+	}
+	return ae
+}
+
+func BenchmarkSequentialEmbedder(b *testing.B) {
+	embedtesting.BenchmarkEmbedder(b, newSequential(b))
+}
+
+func BenchmarkConcurrentEmbedder(b *testing.B) {
+	embedtesting.BenchmarkEmbedder(b, newConcurrentEnv(b))
+}
+
+func TestEmbedder(t *testing.T) {
+	embedtesting.TestEmbedderCall(t, newSequential(t), fmt.Sprintf("[]byte(%q)", testKey), "")
+}
+
+func TestWrongKey(t *testing.T) {
+	embedtesting.TestEmbedderCall(t, newSequential(t), fmt.Sprintf("[]byte(%q)", wrongKey), "wrong key")
+	embedtesting.TestEmbedderCall(t, newSequential(t), "nil", "invalid key size")
+}
+
+func TestEnvEmbedder(t *testing.T) {
+	t.Setenv(testEnv, hex.EncodeToString(testKey))
+	embedtesting.TestEmbedder(t, newConcurrentEnv(t))
+	embedtesting.TestEmbedderBytes(t, newConcurrentEnv(t).(goembed.ConfigurableEmbedder), goembed.Options{})
+	embedtesting.TestEmbedderOpen(t, newConcurrentEnv(t).(goembed.ConfigurableEmbedder), goembed.Options{})
+
+	t.Setenv(testEnv, hex.EncodeToString(wrongKey))
+	embedtesting.TestEmbedderCall(t, newConcurrentEnv(t), "", "wrong key")
+	t.Setenv(testEnv, "")
+	embedtesting.TestEmbedderCall(t, newConcurrentEnv(t), "", "missing or invalid hex-encoded key in $"+testEnv)
+}
+
+// TestReproducible checks that encrypting the same asset twice
+// produces the same output, and that different assets get different
+// nonces.
+func TestReproducible(t *testing.T) {
+	encode, err := encoder(testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out []string
+	for _, s := range []string{"licensed content", "licensed content", "other content"} {
+		r, err := encode(strings.NewReader(s))
+		if err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, r)
+	}
+	if out[0] != out[1] {
+		t.Errorf("same asset encrypted differently: %s, %s", out[0], out[1])
+	}
+	if out[0][:17] == out[2][:17] {
+		t.Errorf("different assets share a nonce: %s, %s", out[0], out[2])
+	}
+}
+
+// TestEncrypted checks that the contents of the assets do not appear
+// in the generated Go source file.
+func TestEncrypted(t *testing.T) {
+	var src bytes.Buffer
+	assets := []*goembed.Asset{{
+		Reader: strings.NewReader("licensed content"),
+		Key:    "/license.txt",
+	}}
+	if _, err := newSequential(t).AssetEmbed(&src, assets, "main", "loadAssets"); err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(src.Bytes(), []byte("licensed")) {
+		t.Errorf("plaintext in generated source:\n%s", src.Bytes())
+	}
+	if !bytes.Contains(src.Bytes(), []byte("func loadAssets(key []byte) (map[string]string, error)")) {
+		t.Errorf("loading function does not take the key:\n%s", src.Bytes())
+	}
+}
+
+func TestParseKey(t *testing.T) {
+	tests := []struct {
+		s    string
+		key  []byte
+		fail bool
+	}{
+		{s: hex.EncodeToString(testKey) + "\n", key: testKey},
+		{s: "000102030405060708090a0b0c0d0e0f", key: []byte("\x00\x01\x02\x03\x04\x05\x06\x07\x08\x09\x0a\x0b\x0c\x0d\x0e\x0f")},
+		{s: "0001", fail: true},
+		{s: "not hex", fail: true},
+		{s: "", fail: true},
+	}
+	for _, tt := range tests {
+		key, err := ParseKey(tt.s)
+		if (err != nil) != tt.fail || !bytes.Equal(key, tt.key) {
+			t.Errorf("ParseKey(%q) = %x, %v", tt.s, key, err)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+// Package ascii85embedder implements an asset embedder that encodes
+// assets as ascii85 strings.
+//
+// The ascii85 alphabet contains the double quote, backslash and
+// backtick characters, so the encoded data is written using the
+// shortest combination of raw and interpreted string literals (see
+// cquoteembedder.Quote).
+package ascii85embedder
+
+import (
+	"bytes"
+	"encoding/ascii85"
+	"io"
+
+	"github.com/jeanfric/goembed"
+	"github.com/jeanfric/goembed/cquoteembedder"
+)
+
+var (
+	imports = [...]string{"encoding/ascii85", "io/ioutil", "strings"}
+)
+
+const (
+	decode = `func(s string) (string, error) {
+		b, err := ioutil.ReadAll(ascii85.NewDecoder(strings.NewReader(s)))
+		if err != nil {
+			return "", err
+		}
+		return string(b), nil
+	}`
+)
+
+func encode(contents io.Reader) (string, error) {
+	var ab bytes.Buffer
+	w := ascii85.NewEncoder(&ab)
+	_, err := io.Copy(w, contents)
+	if err != nil {
+		return "", err
+	}
+	err = w.Close()
+	if err != nil {
+		return "", err
+	}
+
+	return cquoteembedder.Quote(ab.Bytes()), nil
+}
+
+// NewSequential creates a new sequential ascii85embedder asset embedder.
+func NewSequential() goembed.AssetEmbedder {
+	e := goembed.NewSequentialEmbedder(encode, decode, imports[:])
+	e.SetName("ascii85")
+	return e
+}
+
+// NewConcurrent creates a new concurrent ascii85embedder asset embedder.
+func NewConcurrent() goembed.AssetEmbedder {
+	e := goembed.NewConcurrentEmbedder(encode, decode, imports[:])
+	e.SetName("ascii85")
+	return e
+}
@@ -0,0 +1,24 @@
+package ascii85embedder
+
+import (
+	"testing"
+
+	"github.com/jeanfric/goembed"
+	"github.com/jeanfric/goembed/embedtesting"
+)
+
+func BenchmarkSequentialEmbedder(b *testing.B) {
+	embedtesting.BenchmarkEmbedder(b, NewSequential())
+}
+
+func BenchmarkConcurrentEmbedder(b *testing.B) {
+	embedtesting.BenchmarkEmbedder(b, NewConcurrent())
+}
+
+func TestBytesEmbedder(t *testing.T) {
+	embedtesting.TestEmbedderBytes(t, NewSequential().(goembed.ConfigurableEmbedder), goembed.Options{})
+}
+
+func TestOpenEmbedder(t *testing.T) {
+	embedtesting.TestEmbedderOpen(t, NewSequential().(goembed.ConfigurableEmbedder), goembed.Options{})
+}
@@ -0,0 +1,125 @@
+package goembed
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strconv"
+	"strings"
+)
+
+// Expr returns the Go expression holding the string representation of
+// the asset: either its encoded representation, or the contents of
+// the data symbol it was moved to.
+func (p *processedAsset) Expr() string {
+	if p.Symbol != "" {
+		return "string(" + p.Symbol + "[:])"
+	}
+	return p.EncodedRepresentation
+}
+
+// writeAsm moves the string representation of the assets whose
+// encoded representation is larger than the threshold set in the
+// options to read-only data symbols, named after funcName, in the Go
+// assembly source file set in the options.  It does nothing if the
+// options do not set an assembly source file.
+func (e *embedder) writeAsm(funcName string, assets []*processedAsset) error {
+	if e.options.Asm == nil {
+		return nil
+	}
+	if e.options.Open != "" {
+		return errors.New("goembed: the open function does not support assembly output")
+	}
+	w := bufio.NewWriter(e.options.Asm)
+	fmt.Fprintf(w, "#include \"textflag.h\"\n")
+	for i, p := range assets {
+		if len(p.EncodedRepresentation) <= e.options.AsmThreshold {
+			continue
+		}
+		b, err := literalValue(p.EncodedRepresentation)
+		if err != nil {
+			return fmt.Errorf("goembed: %s: %v", p.Key, err)
+		}
+		if len(b) == 0 {
+			continue
+		}
+		p.Symbol = fmt.Sprintf("%sAsm%d", funcName, i)
+		p.SymbolSize = len(b)
+
+		// A DATA directive initializes up to 8 bytes.
+		fmt.Fprintf(w, "\n")
+		for off := 0; off < len(b); off += 8 {
+			chunk := b[off:]
+			if len(chunk) > 8 {
+				chunk = chunk[:8]
+			}
+			fmt.Fprintf(w, "DATA ·%s+%d(SB)/%d, $%s\n", p.Symbol, off, len(chunk), asmString(chunk))
+		}
+		fmt.Fprintf(w, "GLOBL ·%s(SB), RODATA|NOPTR, $%d\n", p.Symbol, len(b))
+	}
+	return w.Flush()
+}
+
+// asmString returns b as a string constant of the Go assembler.
+func asmString(b []byte) string {
+	var s strings.Builder
+	s.WriteByte('"')
+	for _, c := range b {
+		if c >= ' ' && c <= '~' && c != '"' && c != '\\' {
+			s.WriteByte(c)
+		} else {
+			fmt.Fprintf(&s, "\\x%02x", c)
+		}
+	}
+	s.WriteByte('"')
+	return s.String()
+}
+
+// literalValue returns the value of expr, a string literal or a
+// concatenation of string literals, as produced by the encoding
+// functions of the embedders.
+func literalValue(expr string) ([]byte, error) {
+	x, err := parser.ParseExpr(expr)
+	if err != nil {
+		return nil, err
+	}
+	var b []byte
+	var eval func(x ast.Expr) error
+	eval = func(x ast.Expr) error {
+		switch x := x.(type) {
+		case *ast.BasicLit:
+			if x.Kind != token.STRING {
+				break
+			}
+			s, err := strconv.Unquote(x.Value)
+			if err != nil {
+				return err
+			}
+			if x.Value[0] == '`' {
+				// The Go compiler discards carriage
+				// returns from raw string literals.
+				s = strings.Replace(s, "\r", "", -1)
+			}
+			b = append(b, s...)
+			return nil
+		case *ast.BinaryExpr:
+			if x.Op != token.ADD {
+				break
+			}
+			if err := eval(x.X); err != nil {
+				return err
+			}
+			return eval(x.Y)
+		case *ast.ParenExpr:
+			return eval(x.X)
+		}
+		return fmt.Errorf("not a string literal: %.40s", expr)
+	}
+	if err := eval(x); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
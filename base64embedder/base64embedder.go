@@ -11,7 +11,8 @@ import (
 )
 
 var (
-	imports = [...]string{"encoding/base64"}
+	imports       = [...]string{"encoding/base64"}
+	streamImports = [...]string{"encoding/base64", "io", "strings"}
 )
 
 const (
@@ -22,6 +23,15 @@ const (
 		}
 		return string(b), nil
 	}`
+
+	// Each group of 3 bytes is encoded as 4 characters.
+	stream = `func(s string, off int64) (io.Reader, error) {
+		r := base64.NewDecoder(base64.StdEncoding, strings.NewReader(s[off/3*4:]))
+		if _, err := io.CopyN(io.Discard, r, off%3); err != nil {
+			return nil, err
+		}
+		return r, nil
+	}`
 )
 
 func encode(contents io.Reader) (string, error) {
@@ -34,10 +44,16 @@ func encode(contents io.Reader) (string, error) {
 
 // NewSequential creates a new sequential base64embedder asset embedder.
 func NewSequential() goembed.AssetEmbedder {
-	return goembed.NewSequentialEmbedder(encode, decode, imports[:])
+	e := goembed.NewSequentialEmbedder(encode, decode, imports[:])
+	e.SetName("base64")
+	e.SetStream(stream, streamImports[:]...)
+	return e
 }
 
 // NewConcurrent creates a new concurrent base64embedder asset embedder.
 func NewConcurrent() goembed.AssetEmbedder {
-	return goembed.NewConcurrentEmbedder(encode, decode, imports[:])
+	e := goembed.NewConcurrentEmbedder(encode, decode, imports[:])
+	e.SetName("base64")
+	e.SetStream(stream, streamImports[:]...)
+	return e
 }
@@ -0,0 +1,109 @@
+package goembed
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"sort"
+)
+
+// bytesDecodeFunc returns the counterpart of decodeFunc returning a
+// byte slice rather than a string:
+//
+//	func(s string) ([]byte, error) {
+//		// ...
+//	}
+//
+// The decode functions of the embedders typically build a byte slice
+// and convert it to a string when returning it.  bytesDecodeFunc
+// rewrites the return statements of such functions so they return
+// the byte slice itself, which saves copying the decoded data: in
+// the return statements of decodeFunc (other than those of nested
+// function literals), "" becomes nil, string(b) becomes b, and the
+// identifier s becomes []byte(s).  If decodeFunc returns any other
+// expression, bytesDecodeFunc returns false, and the generated
+// source file converts the strings returned by decodeFunc instead.
+func bytesDecodeFunc(decodeFunc string) (string, bool) {
+	const prefix = "package p\n\nvar _ = "
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", prefix+decodeFunc, 0)
+	if err != nil {
+		return "", false
+	}
+	lit, ok := f.Decls[0].(*ast.GenDecl).Specs[0].(*ast.ValueSpec).Values[0].(*ast.FuncLit)
+	if !ok {
+		return "", false
+	}
+	results := lit.Type.Results
+	if results == nil || len(results.List) != 2 || !isIdent(results.List[0].Type, "string") {
+		return "", false
+	}
+
+	// Each edit replaces the text between two offsets of
+	// decodeFunc.
+	type edit struct {
+		start, end int
+		text       string
+	}
+	offset := func(p token.Pos) int {
+		return fset.Position(p).Offset - len(prefix)
+	}
+	src := func(n ast.Node) string {
+		return decodeFunc[offset(n.Pos()):offset(n.End())]
+	}
+	edits := []edit{{
+		start: offset(results.List[0].Type.Pos()),
+		end:   offset(results.List[0].Type.End()),
+		text:  "[]byte",
+	}}
+	ok = true
+	ast.Inspect(lit.Body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			return false
+		case *ast.ReturnStmt:
+			if len(n.Results) != 2 {
+				ok = false
+				return false
+			}
+			r := n.Results[0]
+			e := edit{start: offset(r.Pos()), end: offset(r.End())}
+			switch r := r.(type) {
+			case *ast.BasicLit:
+				if r.Value != `""` {
+					ok = false
+				}
+				e.text = "nil"
+			case *ast.CallExpr:
+				if !isIdent(r.Fun, "string") || len(r.Args) != 1 {
+					ok = false
+					break
+				}
+				e.text = src(r.Args[0])
+			case *ast.Ident:
+				e.text = "[]byte(" + r.Name + ")"
+			default:
+				ok = false
+			}
+			edits = append(edits, e)
+		}
+		return ok
+	})
+	if !ok {
+		return "", false
+	}
+
+	sort.Slice(edits, func(i, j int) bool {
+		return edits[i].start > edits[j].start
+	})
+	for _, e := range edits {
+		decodeFunc = decodeFunc[:e.start] + e.text + decodeFunc[e.end:]
+	}
+	return decodeFunc, true
+}
+
+// isIdent reports whether x is the identifier name.
+func isIdent(x ast.Expr, name string) bool {
+	id, ok := x.(*ast.Ident)
+	return ok && id.Name == name
+}
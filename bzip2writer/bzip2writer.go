@@ -0,0 +1,585 @@
+// Package bzip2writer implements an io.WriteCloser that compresses
+// the data written to it using the bzip2 format, which can be read
+// back using the compress/bzip2 package of the standard Go library.
+//
+// The implementation favors simplicity over speed: it is meant to be
+// used when generating Go source files, where the cost of compression
+// is only paid once.
+package bzip2writer
+
+import (
+	"bytes"
+	"container/heap"
+	"errors"
+	"io"
+)
+
+const (
+	blockMagic = 0x314159265359
+	eosMagic   = 0x177245385090
+
+	// groupSize is the number of symbols coded using the same
+	// Huffman table.
+	groupSize = 50
+
+	// maxCodeLen is the maximum length of a Huffman code.
+	maxCodeLen = 17
+
+	// refinements is the number of times the Huffman tables are
+	// refined after the initial guess.
+	refinements = 4
+)
+
+// A Writer is an io.WriteCloser that compresses the data written to
+// it using bzip2.
+type Writer struct {
+	w         io.Writer
+	level     int
+	maxBlock  int
+	out       bitWriter
+	wroteHead bool
+	closed    bool
+
+	block     []byte // The current block, after the initial run-length encoding
+	blockCRC  uint32
+	streamCRC uint32
+	runByte   byte
+	runLen    int
+}
+
+// New creates a new writer that compresses the data written to it
+// and writes it to w, using the largest (900k) block size.  The
+// compressed data is only complete once the writer has been closed.
+func New(w io.Writer) *Writer {
+	z, _ := NewLevel(w, 9)
+	return z
+}
+
+// NewLevel is like New, but uses a block size of level*100k, where
+// level is between 1 and 9.  Smaller blocks require less memory to
+// compress and decompress, at the expense of compression ratio.
+func NewLevel(w io.Writer, level int) (*Writer, error) {
+	if level < 1 || level > 9 {
+		return nil, errors.New("bzip2writer: invalid level")
+	}
+	return &Writer{
+		w:        w,
+		level:    level,
+		maxBlock: level*100000 - 19,
+		blockCRC: 0xffffffff,
+	}, nil
+}
+
+// Write compresses b and writes the compressed data to the wrapped
+// writer, as blocks are completed.
+func (z *Writer) Write(b []byte) (int, error) {
+	if z.closed {
+		return 0, errors.New("bzip2writer: write to closed writer")
+	}
+	for i, c := range b {
+		if z.runLen > 0 && c == z.runByte && z.runLen < 255 {
+			z.runLen++
+		} else {
+			// A pending run encodes to at most 5 bytes.
+			if len(z.block)+10 > z.maxBlock {
+				if err := z.writeBlock(); err != nil {
+					return i, err
+				}
+			}
+			z.flushRun()
+			z.runByte = c
+			z.runLen = 1
+		}
+		z.blockCRC = updateCRC(z.blockCRC, c)
+	}
+	return len(b), nil
+}
+
+// Close writes the pending compressed data and the end of the
+// stream to the wrapped writer.  It does not close the wrapped
+// writer.
+func (z *Writer) Close() error {
+	if z.closed {
+		return nil
+	}
+	z.closed = true
+	if err := z.writeBlock(); err != nil {
+		return err
+	}
+	z.writeHeader()
+	z.out.WriteBits(48, eosMagic)
+	z.out.WriteBits(32, uint64(z.streamCRC))
+	z.out.Align()
+	return z.flush()
+}
+
+// flushRun applies the initial run-length encoding to the pending
+// run: runs of 4 to 255 identical bytes are written as 4 bytes
+// followed by the number of additional repetitions.
+func (z *Writer) flushRun() {
+	for i := 0; i < z.runLen && i < 4; i++ {
+		z.block = append(z.block, z.runByte)
+	}
+	if z.runLen >= 4 {
+		z.block = append(z.block, byte(z.runLen-4))
+	}
+	z.runLen = 0
+}
+
+func (z *Writer) writeHeader() {
+	if !z.wroteHead {
+		z.out.WriteBits(24, 'B'<<16|'Z'<<8|'h')
+		z.out.WriteBits(8, uint64('0'+z.level))
+		z.wroteHead = true
+	}
+}
+
+func (z *Writer) flush() error {
+	_, err := z.w.Write(z.out.Bytes())
+	z.out.Reset()
+	return err
+}
+
+// writeBlock compresses and writes the current block, if it is not
+// empty, and starts a new block.
+func (z *Writer) writeBlock() error {
+	z.flushRun()
+	if len(z.block) == 0 {
+		return nil
+	}
+	z.writeHeader()
+
+	crc := ^z.blockCRC
+	z.streamCRC = (z.streamCRC<<1 | z.streamCRC>>31) ^ crc
+
+	bwt, origPtr := transform(z.block)
+
+	// Compute the mapping of the bytes in use to consecutive
+	// symbols.
+	var inUse [256]bool
+	for _, c := range z.block {
+		inUse[c] = true
+	}
+	var unseqToSeq [256]byte
+	nInUse := 0
+	for i, used := range inUse {
+		if used {
+			unseqToSeq[i] = byte(nInUse)
+			nInUse++
+		}
+	}
+
+	syms := mtfEncode(bwt, &unseqToSeq, nInUse)
+	alphaSize := nInUse + 2
+
+	z.out.WriteBits(48, blockMagic)
+	z.out.WriteBits(32, uint64(crc))
+	z.out.WriteBits(1, 0) // Not randomized
+	z.out.WriteBits(24, uint64(origPtr))
+
+	// Bitmap of the bytes in use, in 16 ranges of 16 bytes.
+	var ranges uint64
+	for i := 0; i < 16; i++ {
+		for j := 0; j < 16; j++ {
+			if inUse[i*16+j] {
+				ranges |= 1 << uint(15-i)
+				break
+			}
+		}
+	}
+	z.out.WriteBits(16, ranges)
+	for i := 0; i < 16; i++ {
+		if ranges&(1<<uint(15-i)) == 0 {
+			continue
+		}
+		var used uint64
+		for j := 0; j < 16; j++ {
+			if inUse[i*16+j] {
+				used |= 1 << uint(15-j)
+			}
+		}
+		z.out.WriteBits(16, used)
+	}
+
+	lengths, selectors := buildTables(syms, alphaSize)
+
+	z.out.WriteBits(3, uint64(len(lengths)))
+	z.out.WriteBits(15, uint64(len(selectors)))
+	// The selectors are move-to-front encoded, and each resulting
+	// index is written in unary.
+	var order [6]byte
+	for i := range order {
+		order[i] = byte(i)
+	}
+	for _, s := range selectors {
+		j := 0
+		for order[j] != s {
+			j++
+		}
+		copy(order[1:j+1], order[:j])
+		order[0] = s
+		for ; j > 0; j-- {
+			z.out.WriteBits(1, 1)
+		}
+		z.out.WriteBits(1, 0)
+	}
+
+	// The code lengths of each table are delta encoded.
+	codes := make([][]uint32, len(lengths))
+	for t, l := range lengths {
+		cur := l[0]
+		z.out.WriteBits(5, uint64(cur))
+		for _, v := range l {
+			for ; cur < v; cur++ {
+				z.out.WriteBits(2, 2)
+			}
+			for ; cur > v; cur-- {
+				z.out.WriteBits(2, 3)
+			}
+			z.out.WriteBits(1, 0)
+		}
+		codes[t] = assignCodes(l)
+	}
+
+	for i, s := range syms {
+		t := selectors[i/groupSize]
+		z.out.WriteBits(uint(lengths[t][s]), uint64(codes[t][s]))
+	}
+
+	z.block = z.block[:0]
+	z.blockCRC = 0xffffffff
+	return z.flush()
+}
+
+// transform computes the Burrows-Wheeler transform of block, and
+// returns the last column of the sorted rotations of the block,
+// together with the index of the original block in the sorted
+// rotations.
+//
+// The rotations are sorted by prefix doubling: at each step, the
+// rotations are sorted according to their first k bytes, using the
+// ranks computed for their first k/2 bytes.
+func transform(block []byte) ([]byte, int) {
+	n := len(block)
+	sa := make([]int32, n)
+	rank := make([]int32, n)
+	tmp := make([]int32, n)
+
+	var count [257]int32
+	for _, c := range block {
+		count[int(c)+1]++
+	}
+	for i := 1; i < len(count); i++ {
+		count[i] += count[i-1]
+	}
+	for i, c := range block {
+		sa[count[c]] = int32(i)
+		count[c]++
+	}
+	classes := int32(0)
+	for i := range sa {
+		if i > 0 && block[sa[i]] != block[sa[i-1]] {
+			classes++
+		}
+		rank[sa[i]] = classes
+	}
+	classes++
+
+	cnt := make([]int32, n+1)
+	for k := 1; int(classes) < n && k < n; k <<= 1 {
+		// Order the rotations by their second half...
+		for i, s := range sa {
+			s -= int32(k)
+			if s < 0 {
+				s += int32(n)
+			}
+			tmp[i] = s
+		}
+		// ...then stably by their first half.
+		for i := range cnt[:classes+1] {
+			cnt[i] = 0
+		}
+		for _, s := range tmp {
+			cnt[rank[s]+1]++
+		}
+		for i := int32(1); i <= classes; i++ {
+			cnt[i] += cnt[i-1]
+		}
+		for _, s := range tmp {
+			sa[cnt[rank[s]]] = s
+			cnt[rank[s]]++
+		}
+
+		second := func(s int32) int32 {
+			s += int32(k)
+			if s >= int32(n) {
+				s -= int32(n)
+			}
+			return rank[s]
+		}
+		classes = 0
+		for i := range sa {
+			if i > 0 && (rank[sa[i]] != rank[sa[i-1]] || second(sa[i]) != second(sa[i-1])) {
+				classes++
+			}
+			tmp[sa[i]] = classes
+		}
+		classes++
+		rank, tmp = tmp, rank
+	}
+
+	out := make([]byte, n)
+	origPtr := 0
+	for i, s := range sa {
+		if s == 0 {
+			origPtr = i
+			s = int32(n)
+		}
+		out[i] = block[s-1]
+	}
+	return out, origPtr
+}
+
+// mtfEncode applies the move-to-front transform to b, and encodes the
+// runs of zeroes using the RUNA (0) and RUNB (1) symbols.  The
+// returned symbols end with the end-of-block symbol.
+func mtfEncode(b []byte, unseqToSeq *[256]byte, nInUse int) []uint16 {
+	var order [256]byte
+	for i := range order {
+		order[i] = byte(i)
+	}
+	syms := make([]uint16, 0, len(b)+1)
+	zeroes := 0
+	writeZeroes := func() {
+		for zeroes > 0 {
+			zeroes--
+			syms = append(syms, uint16(zeroes&1))
+			zeroes >>= 1
+		}
+	}
+	for _, c := range b {
+		s := unseqToSeq[c]
+		if order[0] == s {
+			zeroes++
+			continue
+		}
+		writeZeroes()
+		j := 1
+		for order[j] != s {
+			j++
+		}
+		copy(order[1:j+1], order[:j])
+		order[0] = s
+		syms = append(syms, uint16(j+1))
+	}
+	writeZeroes()
+	return append(syms, uint16(nInUse+1))
+}
+
+// buildTables computes between 2 and 6 Huffman tables suited to code
+// syms, and selects the table to use for each group of symbols.
+func buildTables(syms []uint16, alphaSize int) ([][]uint8, []byte) {
+	nGroups := 6
+	switch {
+	case len(syms) < 200:
+		nGroups = 2
+	case len(syms) < 600:
+		nGroups = 3
+	case len(syms) < 1200:
+		nGroups = 4
+	case len(syms) < 2400:
+		nGroups = 5
+	}
+
+	var total int
+	freqs := make([]int, alphaSize)
+	for _, s := range syms {
+		freqs[s]++
+		total++
+	}
+
+	// Initially, each table favors a contiguous range of symbols
+	// of roughly equal total frequency.
+	lengths := make([][]uint8, nGroups)
+	lo := 0
+	remaining := total
+	for t := 0; t < nGroups; t++ {
+		target := remaining / (nGroups - t)
+		hi := lo
+		sum := 0
+		for hi < alphaSize && (sum < target || hi == lo) {
+			sum += freqs[hi]
+			hi++
+		}
+		remaining -= sum
+		lengths[t] = make([]uint8, alphaSize)
+		for s := range lengths[t] {
+			if s < lo || s >= hi {
+				lengths[t][s] = 15
+			}
+		}
+		lo = hi
+	}
+
+	selectors := make([]byte, (len(syms)+groupSize-1)/groupSize)
+	for iter := 0; iter < refinements; iter++ {
+		tableFreqs := make([][]int, nGroups)
+		for t := range tableFreqs {
+			tableFreqs[t] = make([]int, alphaSize)
+		}
+		for g := range selectors {
+			group := syms[g*groupSize:]
+			if len(group) > groupSize {
+				group = group[:groupSize]
+			}
+			best, bestCost := 0, -1
+			for t, l := range lengths {
+				cost := 0
+				for _, s := range group {
+					cost += int(l[s])
+				}
+				if bestCost < 0 || cost < bestCost {
+					best, bestCost = t, cost
+				}
+			}
+			selectors[g] = byte(best)
+			for _, s := range group {
+				tableFreqs[best][s]++
+			}
+		}
+		for t := range lengths {
+			lengths[t] = codeLengths(tableFreqs[t], maxCodeLen)
+		}
+	}
+	return lengths, selectors
+}
+
+// A huffmanNode is a node of the tree built to compute code lengths.
+type huffmanNode struct {
+	weight      int
+	symbol      int // -1 for internal nodes
+	left, right *huffmanNode
+}
+
+type huffmanHeap []*huffmanNode
+
+func (h huffmanHeap) Len() int            { return len(h) }
+func (h huffmanHeap) Less(i, j int) bool  { return h[i].weight < h[j].weight }
+func (h huffmanHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *huffmanHeap) Push(x interface{}) { *h = append(*h, x.(*huffmanNode)) }
+func (h *huffmanHeap) Pop() interface{} {
+	old := *h
+	n := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return n
+}
+
+// codeLengths computes the lengths of Huffman codes for the given
+// symbol frequencies, none of which exceeds maxLen.  Every symbol is
+// given a code, as bzip2 requires.
+func codeLengths(freqs []int, maxLen int) []uint8 {
+	weights := make([]int, len(freqs))
+	for i, f := range freqs {
+		weights[i] = f
+		if weights[i] == 0 {
+			weights[i] = 1
+		}
+	}
+	lengths := make([]uint8, len(freqs))
+	for {
+		h := make(huffmanHeap, len(weights))
+		for i, w := range weights {
+			h[i] = &huffmanNode{weight: w, symbol: i}
+		}
+		heap.Init(&h)
+		for h.Len() > 1 {
+			a := heap.Pop(&h).(*huffmanNode)
+			b := heap.Pop(&h).(*huffmanNode)
+			heap.Push(&h, &huffmanNode{weight: a.weight + b.weight, symbol: -1, left: a, right: b})
+		}
+		tooLong := false
+		var walk func(n *huffmanNode, depth int)
+		walk = func(n *huffmanNode, depth int) {
+			if n.symbol >= 0 {
+				if depth > maxLen {
+					tooLong = true
+				}
+				lengths[n.symbol] = uint8(depth)
+				return
+			}
+			walk(n.left, depth+1)
+			walk(n.right, depth+1)
+		}
+		walk(h[0], 0)
+		if !tooLong {
+			return lengths
+		}
+		// Flatten the frequency distribution, and try again.
+		for i, w := range weights {
+			weights[i] = 1 + w/2
+		}
+	}
+}
+
+// assignCodes computes the canonical Huffman codes matching lengths:
+// shorter codes come first and, within a given length, codes are
+// assigned in symbol order.
+func assignCodes(lengths []uint8) []uint32 {
+	codes := make([]uint32, len(lengths))
+	code := uint32(0)
+	for l := uint8(1); l <= maxCodeLen; l++ {
+		for s, sl := range lengths {
+			if sl == l {
+				codes[s] = code
+				code++
+			}
+		}
+		code <<= 1
+	}
+	return codes
+}
+
+// A bitWriter accumulates bits, most significant bit first, into a
+// byte buffer.
+type bitWriter struct {
+	bytes.Buffer
+	bits  uint64
+	nbits uint
+}
+
+// WriteBits writes the n (at most 48) lower bits of v.
+func (w *bitWriter) WriteBits(n uint, v uint64) {
+	w.bits = w.bits<<n | v&(1<<n-1)
+	w.nbits += n
+	for w.nbits >= 8 {
+		w.nbits -= 8
+		w.WriteByte(byte(w.bits >> w.nbits))
+	}
+}
+
+// Align pads the written bits with zeroes up to a byte boundary.
+func (w *bitWriter) Align() {
+	if w.nbits > 0 {
+		w.WriteBits(8-w.nbits, 0)
+	}
+}
+
+var crcTable = func() (t [256]uint32) {
+	for i := range t {
+		c := uint32(i) << 24
+		for j := 0; j < 8; j++ {
+			if c&0x80000000 != 0 {
+				c = c<<1 ^ 0x04c11db7
+			} else {
+				c <<= 1
+			}
+		}
+		t[i] = c
+	}
+	return t
+}()
+
+// updateCRC updates the big-endian CRC-32 used by bzip2 with b.
+func updateCRC(crc uint32, b byte) uint32 {
+	return crc<<8 ^ crcTable[byte(crc>>24)^b]
+}
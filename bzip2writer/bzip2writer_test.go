@@ -0,0 +1,82 @@
+package bzip2writer
+
+import (
+	"bytes"
+	"compress/bzip2"
+	"io/ioutil"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/jeanfric/goembed/embedtesting"
+)
+
+func roundTrip(t *testing.T, level int, b []byte) int {
+	var zb bytes.Buffer
+	w, err := NewLevel(&zb, level)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Write in uneven pieces, to exercise block boundaries.
+	for p := b; len(p) > 0; {
+		n := 1 + len(p)/3
+		if _, err := w.Write(p[:n]); err != nil {
+			t.Fatal(err)
+		}
+		p = p[n:]
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	n := zb.Len()
+	got, err := ioutil.ReadAll(bzip2.NewReader(&zb))
+	if err != nil {
+		t.Fatalf("level %d, %d bytes: %v", level, len(b), err)
+	}
+	if !bytes.Equal(got, b) {
+		t.Fatalf("level %d, %d bytes: round trip mismatch", level, len(b))
+	}
+	return n
+}
+
+func TestRoundTrip(t *testing.T) {
+	random := make([]byte, 300000)
+	rand.New(rand.NewSource(1)).Read(random)
+
+	tests := [][]byte{
+		nil,
+		[]byte("a"),
+		[]byte("ab"),
+		[]byte("aaaa"),
+		[]byte("aaaaa"),
+		bytes.Repeat([]byte("a"), 1000),
+		bytes.Repeat([]byte("abc"), 1000),
+		bytes.Repeat([]byte{0}, 250000),
+		[]byte(strings.Repeat("hello, world\n", 10000)),
+		random[:1000],
+		random,
+	}
+	for _, b := range tests {
+		roundTrip(t, 1, b)
+		roundTrip(t, 9, b)
+	}
+}
+
+func TestRoundTripTestAssets(t *testing.T) {
+	for _, a := range embedtesting.GetTestAssets() {
+		b, err := ioutil.ReadAll(a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		n := roundTrip(t, 9, b)
+		t.Logf("%s: %d -> %d bytes", a.Key, len(b), n)
+	}
+}
+
+func TestInvalidLevel(t *testing.T) {
+	for _, level := range []int{0, 10} {
+		if _, err := NewLevel(ioutil.Discard, level); err == nil {
+			t.Errorf("NewLevel(%d) succeeded", level)
+		}
+	}
+}
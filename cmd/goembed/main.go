@@ -2,13 +2,20 @@
 // encoded version of the contents of a directory.
 //
 // The generated source code has no external import dependencies; it
-// relies solely on the standard Go library.
+// relies solely on the standard Go library.  It requires Go 1.13 or
+// later, since it wraps errors with %w, or Go 1.16 or later with
+// "-open", since the open function returns an io.ReadSeekCloser and
+// uses io.Discard.
 //
 // The generated code provides a function that loads and returns the
 // embedded assets, with the following signature:
 //
 // 	func loadAssets() (map[string]string, error)
 //
+// With "-type bytes", the assets are returned as byte slices instead:
+//
+// 	func loadAssets() (map[string][]byte, error)
+//
 // Given this "testdata" directory:
 //
 //	testdata/
@@ -25,6 +32,14 @@
 // The paths will all begin at "/" and use forward slashes ("/") as
 // path separators.
 //
+// If an asset cannot be decoded, the error returned by loadAssets
+// names its key and the embedding algorithm, such as "/index.html:
+// zbase64: unexpected EOF".  The generated code also provides a
+// function that panics with that error instead, for programs that
+// cannot run without their assets:
+//
+// 	func mustLoadAssets() map[string]string
+//
 // Goembed is useful in combination with "go generate" to bundle static
 // assets in a program binary.  For example, to embed all files under the
 // "static" directory:
@@ -36,30 +51,145 @@
 // 	$ go generate
 // 	$ go build
 //
+// With "-open openAsset", the generated code also provides a function
+// that opens a single asset, and decodes it as it is read:
+//
+// 	func openAsset(name string) (io.ReadSeekCloser, error)
+//
+// With "-consts Asset", the generated code also declares a constant
+// holding the key of each asset, and a slice listing them, sorted, so
+// that renaming an asset breaks the build of the code referring to it:
+//
+// 	const Asset_img_gopher__dpng = "/img/gopher.png"
+// 	var assetNames = []string{Asset_img_gopher__dpng, ...}
+//
+// The names of the constants keep the letters and digits of the keys,
+// and escape the other characters, so that distinct keys get distinct
+// names: "/a-b" and "/a_b" are held by Asset_a__hb and Asset_a__ub.
+//
+// With "-digests", the generated code also records the SHA-256 digest
+// of each asset, and of the whole bundle, for logging and cache keys:
+//
+// 	func loadAssetsDigest(key string) (string, bool)
+// 	const loadAssetsBundleDigest = "..."
+//
+// With "-verify", the loading function also checks the digest of each
+// asset it decodes, and returns an error naming the first asset that
+// does not match.
+//
+// Assets with identical contents are embedded only once, and each of
+// the duplicates is reported on the standard error output.
+//
 // Goembed supports encoding the data using the following algorithms:
 //
 //	* quote: quoted Go string
+//	* cquote: compact quoted Go string, with raw string segments
 //	* hex: hex-encoded
 //	* base64: base64-encoded
 //	* zhex: zlib-compressed, hex-encoded
 //	* zbase64: zlib-compressed, base64-encoded
+//	* zdictbase64: DEFLATE-compressed with a preset dictionary
+//	  shared by all assets, base64-encoded
+//	* gzbase64: gzip-compressed, base64-encoded (also generates a
+//	  function, named after the loading function with "Gzip"
+//	  appended, returning the gzip-compressed assets)
+//	* zbz2base64: bzip2-compressed, base64-encoded
+//	* lzwbase64: LZW-compressed, base64-encoded
+//	* lz4base64: LZ4-compressed, base64-encoded
+//	* lzmabase64: LZMA-compressed, base64-encoded
+//	* ascii85: ascii85-encoded
+//	* zascii85: zlib-compressed, ascii85-encoded
+//	* aesgcm: AES-GCM-encrypted, base64-encoded
+//
+// The aesgcm algorithm encrypts the assets with the hex-encoded key
+// read from the file named by -key, or else from the environment
+// variable named by -keyenv.  The key is not embedded: with -keyenv,
+// the loading function reads it from that environment variable when
+// it is called, and otherwise takes it as a parameter:
+//
+// 	func loadAssets(key []byte) (map[string]string, error)
+//
+// The nonces are derived from the key and the contents of the assets,
+// so encrypting the same assets with the same key generates the same
+// file.
 //
 // Usage:
 //	goembed [-package p] [-func f] [-o output] directory
 //
 // The flags and their default values are:
+//	-asm=-1
+//		store the encoded assets larger than this many bytes in a
+//		Go assembly file, named after the generated file with a
+//		".s" extension (-1: never)
+//	-blob=false
+//		store all assets in a single string constant, and
+//		generate a function looking up an asset by key (quote and
+//		cquote algorithms)
 //	-c=false
 //		use concurrent version of the chosen algorithm
+//	-chunk=0
+//		compress the assets larger than this many bytes in chunks
+//		of this size, so that the open function decompresses only
+//		the chunks it reads (zbase64 and zhex algorithms; 0: never)
+//	-consts=""
+//		prefix of the names of constants holding the key of each
+//		asset, also listed in a slice named after the prefix
+//		followed by "Names" (none if empty)
+//	-digests=false
+//		record the SHA-256 digest of each asset and of the whole
+//		bundle, and generate a function returning the digest of an
+//		asset
 //	-e="quote"
 //		embedding algorithm
+//	-exhaustive=false
+//		compress each asset at every zlib level and keep the
+//		smallest result, reporting the savings (zbase64 and zhex
+//		algorithms; incompatible with -level)
 //	-func="loadAssets"
 //		name of loading function
+//	-hash=false
+//		like -blob, but look up assets using a perfect hash of the
+//		keys computed at generation time
+//	-key=""
+//		file holding the hex-encoded key of the aesgcm algorithm
+//		(default: read the key from the -keyenv environment
+//		variable)
+//	-keyenv=""
+//		environment variable holding the hex-encoded key of the
+//		aesgcm algorithm, read by the loading function rather than
+//		passed to it
+//	-level=-1
+//		zlib compression level of the zbase64 and zhex algorithms
+//		(-2: Huffman only, -1: default, 0: none, 1-9: fastest to
+//		best)
+//	-lookup=""
+//		name of the lookup function of the -blob and -hash modes
+//		(default: name of loading function followed by "Lookup")
 //	-o="assets.generated.go"
 //		name of generated file
+//	-open=""
+//		name of an additional function opening a single asset as
+//		an io.ReadSeekCloser, decoding it as it is read (none if
+//		empty)
 //	-package="main"
 //		package of the generated source file (if $GOPACKAGE is
 //		set, such as when using "go generate", $GOPACKAGE
 //		takes precedence)
+//	-parallel=false
+//		decode the assets concurrently, with up to GOMAXPROCS
+//		assets decoded at the same time (implies -table)
+//	-solid=false
+//		concatenate all assets and encode them as a single piece
+//		of data
+//	-table=false
+//		decode the assets in a loop over a table, rather than with
+//		statements per asset (for very large numbers of assets)
+//	-type="string"
+//		type of the values of the map returned by the loading
+//		function (string or bytes)
+//	-verify=false
+//		make the loading function check the SHA-256 digest of each
+//		asset it decodes (implies -digests)
 //
 // See also: package github.com/jeanfric/embedfs implements an
 // http.FileSystem backed by a map[string]string, compatible directly
@@ -68,19 +198,77 @@
 package main
 
 import (
+	"compress/zlib"
+	"errors"
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"runtime"
+	"strings"
 
 	"github.com/jeanfric/goembed"
+	"github.com/jeanfric/goembed/aesgcmembedder"
+	"github.com/jeanfric/goembed/ascii85embedder"
 	"github.com/jeanfric/goembed/base64embedder"
+	"github.com/jeanfric/goembed/cquoteembedder"
+	"github.com/jeanfric/goembed/gzbase64embedder"
 	"github.com/jeanfric/goembed/hexembedder"
+	"github.com/jeanfric/goembed/lz4base64embedder"
+	"github.com/jeanfric/goembed/lzmabase64embedder"
+	"github.com/jeanfric/goembed/lzwbase64embedder"
 	"github.com/jeanfric/goembed/quoteembedder"
+	"github.com/jeanfric/goembed/zascii85embedder"
 	"github.com/jeanfric/goembed/zbase64embedder"
+	"github.com/jeanfric/goembed/zbz2base64embedder"
+	"github.com/jeanfric/goembed/zdictbase64embedder"
 	"github.com/jeanfric/goembed/zhexembedder"
+	"github.com/jeanfric/goembed/zlibcompress"
 )
 
+// readKey returns the key of the aesgcm algorithm, read from keyFile,
+// or else from the environment variable keyEnv.
+func readKey(keyFile, keyEnv string) ([]byte, error) {
+	if keyFile != "" {
+		b, err := ioutil.ReadFile(keyFile)
+		if err != nil {
+			return nil, err
+		}
+		return aesgcmembedder.ParseKey(string(b))
+	}
+	if keyEnv == "" {
+		return nil, errors.New("the aesgcm algorithm needs a key file (-key) or environment variable (-keyenv)")
+	}
+	return aesgcmembedder.ParseKey(os.Getenv(keyEnv))
+}
+
+// newCompressor returns the zlib compressor of the zbase64 and zhex
+// algorithms configured by the flags.  Exhaustive mode tries every
+// level, so it cannot be combined with -level.
+func newCompressor(level int, exhaustive bool, chunkSize int) (*zlibcompress.Compressor, error) {
+	var c *zlibcompress.Compressor
+	if exhaustive {
+		var err error
+		flag.Visit(func(f *flag.Flag) {
+			if f.Name == "level" {
+				err = errors.New("-level cannot be used with -exhaustive")
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+		c = zlibcompress.NewExhaustive(os.Stderr)
+	} else {
+		var err error
+		c, err = zlibcompress.New(level)
+		if err != nil {
+			return nil, err
+		}
+	}
+	c.SetChunkSize(chunkSize)
+	return c, nil
+}
+
 func usage() {
 	details := `
 usage: goembed [-package p] [-func f] [-o output] directory
@@ -109,7 +297,7 @@ See also: https://github.com/jeanfric/embedfs.
 Goembed flags:
 `
 
-	fmt.Fprintf(os.Stderr, details)
+	fmt.Fprint(os.Stderr, details)
 	flag.PrintDefaults()
 	os.Exit(2)
 }
@@ -117,13 +305,32 @@ Goembed flags:
 func main() {
 	runtime.GOMAXPROCS(runtime.NumCPU())
 
-	var destFile, packageName, fnName, embedder string
-	var concurrent bool
+	var destFile, packageName, fnName, embedder, valueType, keyFile, keyEnv string
+	var concurrent, exhaustive bool
+	var options goembed.Options
+	var level, asmThreshold, chunkSize int
 	flag.StringVar(&packageName, "package", "main", "package of the generated source file (if $GOPACKAGE is set, such as when using \"go generate\", $GOPACKAGE takes precedence)")
 	flag.StringVar(&fnName, "func", "loadAssets", "name of loading function")
 	flag.StringVar(&destFile, "o", "assets.generated.go", "name of generated file")
 	flag.StringVar(&embedder, "e", "quote", "embedding algorithm")
 	flag.BoolVar(&concurrent, "c", false, "use concurrent version of the chosen algorithm")
+	flag.IntVar(&asmThreshold, "asm", -1, "store the encoded assets larger than this many bytes in a Go assembly file, named after the generated file with a \".s\" extension (-1: never)")
+	flag.BoolVar(&options.Blob, "blob", false, "store all assets in a single string constant, and generate a function looking up an asset by key (quote and cquote algorithms)")
+	flag.BoolVar(&options.Hash, "hash", false, "like -blob, but look up assets using a perfect hash of the keys computed at generation time")
+	flag.StringVar(&options.Lookup, "lookup", "", "name of the lookup function of the -blob and -hash modes (default: name of loading function followed by \"Lookup\")")
+	flag.StringVar(&options.Consts, "consts", "", "prefix of the names of constants holding the key of each asset, also listed in a slice named after the prefix followed by \"Names\" (none if empty)")
+	flag.BoolVar(&options.Digests, "digests", false, "record the SHA-256 digest of each asset and of the whole bundle, and generate a function returning the digest of an asset")
+	flag.BoolVar(&options.Verify, "verify", false, "make the loading function check the SHA-256 digest of each asset it decodes (implies -digests)")
+	flag.StringVar(&options.Open, "open", "", "name of an additional function opening a single asset as an io.ReadSeekCloser, decoding it as it is read (none if empty)")
+	flag.BoolVar(&options.Solid, "solid", false, "concatenate all assets and encode them as a single piece of data")
+	flag.StringVar(&valueType, "type", "string", "type of the values of the map returned by the loading function (string or bytes)")
+	flag.BoolVar(&options.Parallel, "parallel", false, "decode the assets concurrently, with up to GOMAXPROCS assets decoded at the same time (implies -table)")
+	flag.BoolVar(&options.Table, "table", false, "decode the assets in a loop over a table, rather than with statements per asset (for very large numbers of assets)")
+	flag.IntVar(&level, "level", zlib.DefaultCompression, "zlib compression level of the zbase64 and zhex algorithms (-2: Huffman only, -1: default, 0: none, 1-9: fastest to best)")
+	flag.IntVar(&chunkSize, "chunk", 0, "compress the assets larger than this many bytes in chunks of this size, so that the open function decompresses only the chunks it reads (zbase64 and zhex algorithms; 0: never)")
+	flag.StringVar(&keyFile, "key", "", "file holding the hex-encoded key of the aesgcm algorithm (default: read the key from the -keyenv environment variable)")
+	flag.StringVar(&keyEnv, "keyenv", "", "environment variable holding the hex-encoded key of the aesgcm algorithm, read by the loading function rather than passed to it")
+	flag.BoolVar(&exhaustive, "exhaustive", false, "compress each asset at every zlib level and keep the smallest result, reporting the savings (zbase64 and zhex algorithms; incompatible with -level)")
 	flag.Usage = usage
 	flag.Parse()
 
@@ -146,6 +353,27 @@ func main() {
 		os.Exit(1)
 	}
 
+	switch valueType {
+	case "string":
+	case "bytes":
+		options.Bytes = true
+	default:
+		fmt.Fprintf(os.Stderr, "unknown value type \"%s\"\n", valueType)
+		os.Exit(1)
+	}
+
+	if asmThreshold >= 0 {
+		asmFile := strings.TrimSuffix(destFile, ".go") + ".s"
+		asm, err := os.Create(asmFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		defer asm.Close()
+		options.Asm = asm
+		options.AsmThreshold = asmThreshold
+	}
+
 	assets, err := goembed.FindAssets(srcPath)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "%v\n", err)
@@ -156,10 +384,50 @@ func main() {
 
 	switch embedder {
 	case "zbase64":
+		var c *zlibcompress.Compressor
+		if c, err = newCompressor(level, exhaustive, chunkSize); err != nil {
+			break
+		}
+		if concurrent {
+			ae = zbase64embedder.NewConcurrentCompressor(c)
+		} else {
+			ae = zbase64embedder.NewSequentialCompressor(c)
+		}
+	case "zdictbase64":
 		if concurrent {
-			ae = zbase64embedder.NewConcurrent()
+			ae = zdictbase64embedder.NewConcurrent()
 		} else {
-			ae = zbase64embedder.NewSequential()
+			ae = zdictbase64embedder.NewSequential()
+		}
+	case "gzbase64":
+		if concurrent {
+			ae = gzbase64embedder.NewConcurrent()
+		} else {
+			ae = gzbase64embedder.NewSequential()
+		}
+	case "zbz2base64":
+		if concurrent {
+			ae = zbz2base64embedder.NewConcurrent()
+		} else {
+			ae = zbz2base64embedder.NewSequential()
+		}
+	case "lzwbase64":
+		if concurrent {
+			ae = lzwbase64embedder.NewConcurrent()
+		} else {
+			ae = lzwbase64embedder.NewSequential()
+		}
+	case "lz4base64":
+		if concurrent {
+			ae = lz4base64embedder.NewConcurrent()
+		} else {
+			ae = lz4base64embedder.NewSequential()
+		}
+	case "lzmabase64":
+		if concurrent {
+			ae = lzmabase64embedder.NewConcurrent()
+		} else {
+			ae = lzmabase64embedder.NewSequential()
 		}
 	case "base64":
 		if concurrent {
@@ -174,10 +442,14 @@ func main() {
 			ae = hexembedder.NewSequential()
 		}
 	case "zhex":
+		var c *zlibcompress.Compressor
+		if c, err = newCompressor(level, exhaustive, chunkSize); err != nil {
+			break
+		}
 		if concurrent {
-			ae = zhexembedder.NewConcurrent()
+			ae = zhexembedder.NewConcurrentCompressor(c)
 		} else {
-			ae = zhexembedder.NewSequential()
+			ae = zhexembedder.NewSequentialCompressor(c)
 		}
 	case "quote":
 		if concurrent {
@@ -185,10 +457,67 @@ func main() {
 		} else {
 			ae = quoteembedder.NewSequential()
 		}
+	case "cquote":
+		if concurrent {
+			ae = cquoteembedder.NewConcurrent()
+		} else {
+			ae = cquoteembedder.NewSequential()
+		}
+	case "ascii85":
+		if concurrent {
+			ae = ascii85embedder.NewConcurrent()
+		} else {
+			ae = ascii85embedder.NewSequential()
+		}
+	case "zascii85":
+		if concurrent {
+			ae = zascii85embedder.NewConcurrent()
+		} else {
+			ae = zascii85embedder.NewSequential()
+		}
+	case "aesgcm":
+		var key []byte
+		if key, err = readKey(keyFile, keyEnv); err != nil {
+			break
+		}
+		switch {
+		case keyEnv != "" && concurrent:
+			ae, err = aesgcmembedder.NewConcurrentEnv(key, keyEnv)
+		case keyEnv != "":
+			ae, err = aesgcmembedder.NewSequentialEnv(key, keyEnv)
+		case concurrent:
+			ae, err = aesgcmembedder.NewConcurrent(key)
+		default:
+			ae, err = aesgcmembedder.NewSequential(key)
+		}
 	default:
 		fmt.Fprintf(os.Stderr, "unknown embedding algorithm \"%s\"\n", embedder)
 		os.Exit(1)
 	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	if embedder != "zbase64" && embedder != "zhex" {
+		flag.Visit(func(f *flag.Flag) {
+			switch f.Name {
+			case "level", "exhaustive", "chunk":
+				fmt.Fprintf(os.Stderr, "embedding algorithm \"%s\" does not support -%s\n", embedder, f.Name)
+				os.Exit(1)
+			}
+		})
+	}
+
+	if c, ok := ae.(goembed.ConfigurableEmbedder); ok {
+		// Report the assets that are not embedded because
+		// they duplicate other assets.
+		options.Log = os.Stderr
+		c.SetOptions(options)
+	} else if options != (goembed.Options{}) {
+		fmt.Fprintf(os.Stderr, "embedding algorithm \"%s\" does not support layout options\n", embedder)
+		os.Exit(1)
+	}
 
 	if _, err := ae.AssetEmbed(dest, assets, packageName, fnName); err != nil {
 		fmt.Fprintf(os.Stderr, "%v\n", err)
@@ -17,6 +17,13 @@
 //
 // If the assets don't match with the original files, goembedtest will
 // exit with a failure status code.
+//
+// With -t, goembedtest also reports the time spent loading the
+// assets, and the value of GOMAXPROCS, which bounds the number of
+// assets decoded concurrently by loaders generated with -parallel:
+//
+//	$ GOMAXPROCS=1 goembedtest -q -t testdata
+//	$ goembedtest -q -t testdata
 package main
 
 //go:generate goembed testdata
@@ -28,16 +35,19 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"runtime"
+	"time"
 )
 
 func usage() {
-	fmt.Fprintf(os.Stderr, "usage: goembedtest [-q] directory\n")
+	fmt.Fprintf(os.Stderr, "usage: goembedtest [-q] [-t] directory\n")
 	flag.PrintDefaults()
 	os.Exit(2)
 }
 func main() {
-	var quiet bool
+	var quiet, timing bool
 	flag.BoolVar(&quiet, "q", false, "quiet")
+	flag.BoolVar(&timing, "t", false, "report the time spent loading the assets")
 	flag.Usage = usage
 	flag.Parse()
 
@@ -47,11 +57,15 @@ func main() {
 
 	rootPath := flag.Args()[0]
 
+	start := time.Now()
 	assets, err := loadAssets()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "%v\n", err)
 		os.Exit(1)
 	}
+	if timing {
+		fmt.Fprintf(os.Stdout, "load\t%v\tGOMAXPROCS=%d\n", time.Since(start), runtime.GOMAXPROCS(0))
+	}
 
 	var results []string
 	fail := false
@@ -8,9 +8,7 @@ import (
 // A concurrent embedder is an embedder that concurrently encodes
 // assets, with up to runtime.NumCPU() concurrent embedders.
 type ConcurrentEmbedder struct {
-	encodeFunc func(contents io.Reader) (string, error)
-	decodeFunc string
-	imports    []string
+	embedder
 }
 
 // NewConcurrentEmbedder creates a new concurrent embedder that
@@ -20,10 +18,11 @@ type ConcurrentEmbedder struct {
 // imports.  The decodeFunc will wrap each string representation
 // produced by the encodeFunc.
 //
-// The encodeFunc should return a string enclosed in its delimiters
-// (double quotes or backticks).  The embedder implementation can thus
-// choose if it wants to return a quoted string (with double quotes)
-// or a raw string (with backticks).
+// The encodeFunc is called with the *Asset being encoded, so it can
+// make use of its Key.  It should return a string enclosed in its
+// delimiters (double quotes or backticks).  The embedder
+// implementation can thus choose if it wants to return a quoted
+// string (with double quotes) or a raw string (with backticks).
 //
 // The decodeFunc must be written in this form:
 //
@@ -38,9 +37,11 @@ type ConcurrentEmbedder struct {
 // decodeFunc.
 func NewConcurrentEmbedder(encodeFunc func(io.Reader) (string, error), decodeFunc string, imports []string) *ConcurrentEmbedder {
 	return &ConcurrentEmbedder{
-		encodeFunc: encodeFunc,
-		decodeFunc: decodeFunc,
-		imports:    imports,
+		embedder{
+			encodeFunc: encodeFunc,
+			decodeFunc: decodeFunc,
+			imports:    imports,
+		},
 	}
 }
 
@@ -51,54 +52,60 @@ func NewConcurrentEmbedder(encodeFunc func(io.Reader) (string, error), decodeFun
 //
 // 	func funcName() (map[string]string, error)
 func (a *ConcurrentEmbedder) AssetEmbed(dst io.Writer, assets []*Asset, packageName, funcName string) (int, error) {
-	assetChannel := make(chan *Asset, runtime.NumCPU())
-	complete := make(chan *processedAsset, len(assets))
+	if a.options.Solid {
+		return a.embedSolid(dst, assets, packageName, funcName)
+	}
+	if a.options.Blob || a.options.Hash {
+		return a.embedBlob(dst, assets, packageName, funcName)
+	}
+
+	processed, prelude, encodeFunc, err := a.prepare(assets)
+	if err != nil {
+		return 0, err
+	}
+
+	assetChannel := make(chan *processedAsset, runtime.NumCPU())
+	complete := make(chan *processedAsset, len(processed))
 	for i := 0; i < runtime.NumCPU(); i++ {
-		processAssets := func(assetChannel chan *Asset, complete chan *processedAsset) {
+		processAssets := func(assetChannel chan *processedAsset, complete chan *processedAsset) {
 			for {
 				select {
 				case req, ok := <-assetChannel:
 					if !ok {
 						return
 					}
-					s, err := a.encodeFunc(req)
-					complete <- &processedAsset{
-						Asset: req,
-						EncodedRepresentation: s,
-						Error: err,
-					}
+					req.EncodedRepresentation, req.Error = encodeFunc(req.Asset)
+					complete <- req
 				}
 			}
 		}
 		go processAssets(assetChannel, complete)
 	}
 
-	for _, a := range assets {
-		assetChannel <- a
+	for _, p := range processed {
+		assetChannel <- p
 	}
 
-	queueResults := make(map[string]*processedAsset)
-	for range assets {
+	for range processed {
 		r := <-complete
 		if r.Error != nil {
 			return 0, r.Error
 		}
-		queueResults[r.Asset.Key] = r
 	}
 	close(assetChannel)
 	close(complete)
 
-	g := &generatedFileData{
-		PackageName: packageName,
-		FuncName:    funcName,
-		Imports:     a.imports,
-		DecodeFunc:  a.decodeFunc,
-		Assets:      make([]*processedAsset, len(assets)),
-	}
-	for i, a := range assets {
-		g.Assets[i] = queueResults[a.Key]
+	if err := a.writeAsm(funcName, processed); err != nil {
+		return 0, err
 	}
 
+	g := a.fileData(packageName, funcName, prelude, processed)
+	a.openData(g)
+	a.parallelData(g)
+	a.digestData(g, processed)
+	if err := a.constData(g, processed); err != nil {
+		return 0, err
+	}
 	n, err := generateEmbedFile(dst, g)
 	if err != nil {
 		return n, err
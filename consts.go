@@ -0,0 +1,129 @@
+package goembed
+
+import (
+	"fmt"
+	"go/token"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// An assetConst is a constant holding the key of an asset.  Pad
+// holds the spaces following Name, so that the constants are aligned
+// as gofmt would align them.
+type assetConst struct {
+	Name, Pad, Key string
+}
+
+// constData completes g with the constants naming the keys of the
+// assets, if the options ask so.
+func (e *embedder) constData(g *generatedFileData, distinct []*processedAsset) error {
+	prefix := e.options.Consts
+	if prefix == "" {
+		return nil
+	}
+	if !token.IsIdentifier(prefix) {
+		return fmt.Errorf("goembed: invalid constant prefix %q", prefix)
+	}
+	var keys []string
+	for _, a := range distinct {
+		keys = append(keys, a.Keys...)
+	}
+	sort.Strings(keys)
+	r, n := utf8.DecodeRuneInString(prefix)
+	g.NamesVar = string(unicode.ToLower(r)) + prefix[n:] + "Names"
+
+	// The identifiers declared by the generated Go source file
+	// cannot be used.
+	taken := map[string]bool{
+		g.MustFunc:   true,
+		g.OpenFunc:   true,
+		g.LookupFunc: true,
+		g.NamesVar:   true,
+	}
+	for _, s := range []string{"", g.RawSuffix, "Error", "Digests", "Digest", "BundleDigest", "Verify", "Parallel", "Stream", "Encoded", "Keys", "Index", "Blob", "Displace", "Mix"} {
+		taken[g.FuncName+s] = true
+	}
+	for _, a := range g.Symbols {
+		taken[a.Symbol] = true
+	}
+	width := 0
+	for _, k := range keys {
+		// Distinct keys get distinct names.
+		name := constName(prefix, k)
+		if taken[name] {
+			return fmt.Errorf("goembed: constant %s of asset %q collides with a generated identifier", name, k)
+		}
+		g.Consts = append(g.Consts, &assetConst{
+			Name: name,
+			Key:  k,
+		})
+		if w := utf8.RuneCountInString(name); w > width {
+			width = w
+		}
+	}
+	for _, c := range g.Consts {
+		c.Pad = strings.Repeat(" ", width-utf8.RuneCountInString(c.Name))
+	}
+	return nil
+}
+
+// constEscapes maps the separators commonly found in keys to the
+// letters following "__" in the names of the constants.
+var constEscapes = map[rune]byte{
+	'/': 's',
+	'.': 'd',
+	'-': 'h',
+	'_': 'u',
+	' ': 'w',
+}
+
+// constName returns the name of the constant holding key: prefix
+// followed by key, escaped so that distinct keys get distinct names.
+// Letters and digits are kept as is.  A slash becomes "_", unless
+// another separator or an invalid byte follows it, and any other
+// separator becomes "__" followed by its letter in constEscapes, or by
+// "x", its hexadecimal code point and "_".  Invalid UTF-8 bytes become
+// "__b" followed by their two hexadecimal digits.
+func constName(prefix, key string) string {
+	isWord := func(r rune) bool {
+		return unicode.IsLetter(r) || unicode.IsDigit(r)
+	}
+	var name strings.Builder
+	name.WriteString(prefix)
+	for i := 0; i < len(key); {
+		r, n := utf8.DecodeRuneInString(key[i:])
+		switch next, _ := utf8.DecodeRuneInString(key[i+n:]); {
+		case r == utf8.RuneError && n == 1:
+			fmt.Fprintf(&name, "__b%02x", key[i])
+		case isWord(r):
+			name.WriteRune(r)
+		case r == '/' && (i+n == len(key) || isWord(next)):
+			name.WriteByte('_')
+		default:
+			name.WriteString("__")
+			if c, ok := constEscapes[r]; ok {
+				name.WriteByte(c)
+			} else {
+				fmt.Fprintf(&name, "x%x_", r)
+			}
+		}
+		i += n
+	}
+	return name.String()
+}
+
+// constsTemplate declares the constants naming the keys of the
+// assets, and the list of the keys.
+const constsTemplate = `{{if .Consts}}
+// The keys of the assets.
+const ({{range .Consts}}
+	{{.Name}}{{.Pad}} = {{printf "%q" .Key}}{{end}}
+)
+
+// {{.NamesVar}} lists the keys of the assets, sorted.
+var {{.NamesVar}} = []string{ {{- range .Consts}}
+	{{.Name}},{{end}}
+}
+{{end}}`
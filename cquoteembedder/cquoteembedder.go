@@ -0,0 +1,214 @@
+// Package cquoteembedder implements an asset embedder that encodes
+// assets as compact Go string literals.
+//
+// Unlike quoteembedder, which relies on strconv.Quote, the compact
+// encoding keeps every valid UTF-8 rune that Go source code permits
+// as is (including non-printable runes), only escapes the bytes that
+// cannot appear verbatim in a string literal, and switches to raw
+// (backtick) string segments when doing so yields shorter output.
+package cquoteembedder
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"unicode/utf8"
+
+	"github.com/jeanfric/goembed"
+)
+
+var (
+	imports       = [...]string{}
+	streamImports = [...]string{"io", "strings"}
+)
+
+const (
+	decode string = `func(s string) (string, error) {
+		return s, nil
+	}`
+	stream = `func(s string, off int64) (io.Reader, error) {
+		return strings.NewReader(s[off:]), nil
+	}`
+)
+
+const (
+	// bom is the byte order mark, which the Go compiler rejects
+	// anywhere but at the very beginning of a source file.
+	bom = 0xfeff
+
+	// switchCost is the number of bytes needed to close a string
+	// segment and open one of the other kind, as formatted by
+	// gofmt: a closing delimiter, " + ", and an opening delimiter.
+	switchCost = 5
+
+	// impossible is the cost of a rune that cannot be represented
+	// in a raw string segment.
+	impossible = int(^uint(0) >> 2)
+)
+
+// interpretedCost returns the number of bytes needed to represent the
+// rune r, of size bytes, in an interpreted (double-quoted) string
+// literal.  An invalid byte is represented by r == utf8.RuneError and
+// size == 1.
+func interpretedCost(r rune, size int) int {
+	switch {
+	case r == utf8.RuneError && size == 1:
+		return 4 // \xNN
+	case r == 0:
+		return 4 // \x00
+	case r == bom:
+		return 6 // \ufeff
+	case r == '\n', r == '"', r == '\\':
+		return 2
+	}
+	return size
+}
+
+// rawCost returns the number of bytes needed to represent the rune r,
+// of size bytes, in a raw (backtick) string literal.
+func rawCost(r rune, size int) int {
+	switch {
+	case r == utf8.RuneError && size == 1:
+		return impossible
+	case r == 0, r == bom, r == '`':
+		return impossible
+	case r == '\r':
+		// Carriage returns are discarded from raw string
+		// literals by the compiler.
+		return impossible
+	}
+	return size
+}
+
+// Quote returns the shortest Go expression made of concatenated
+// string literals that evaluates to b, and that can be embedded in a
+// gofmt-formatted Go source file.
+func Quote(b []byte) string {
+	// Tokenize the input into runes and invalid bytes.
+	type token struct {
+		r    rune
+		size int
+	}
+	var tokens []token
+	for i := 0; i < len(b); {
+		r, size := utf8.DecodeRune(b[i:])
+		tokens = append(tokens, token{r, size})
+		i += size
+	}
+
+	// Find the cheapest segmentation using dynamic programming:
+	// costI and costR hold the cost of the best encoding of the
+	// tokens seen so far, ending in an interpreted or in a raw
+	// segment, respectively.  fromI and fromR record, for each
+	// token, whether the best encoding ending in the given
+	// segment kind was reached by switching from the other kind.
+	costI, costR := 1, 1
+	fromI := make([]bool, len(tokens))
+	fromR := make([]bool, len(tokens))
+	for k, t := range tokens {
+		nextI, nextR := costI, costR
+		if costR+switchCost < nextI {
+			nextI = costR + switchCost
+			fromI[k] = true
+		}
+		if costI+switchCost < nextR {
+			nextR = costI + switchCost
+			fromR[k] = true
+		}
+		nextI += interpretedCost(t.r, t.size)
+		nextR += rawCost(t.r, t.size)
+		if nextR > impossible {
+			nextR = impossible
+		}
+		costI, costR = nextI, nextR
+	}
+
+	// Walk back through the choices to find out which kind of
+	// segment each token belongs to.
+	raw := make([]bool, len(tokens))
+	inRaw := costR < costI
+	for k := len(tokens) - 1; k >= 0; k-- {
+		raw[k] = inRaw
+		if inRaw && fromR[k] || !inRaw && fromI[k] {
+			inRaw = !inRaw
+		}
+	}
+
+	var buf bytes.Buffer
+	buf.Grow(len(b) + 2)
+	if len(tokens) == 0 || !raw[0] {
+		buf.WriteByte('"')
+	} else {
+		buf.WriteByte('`')
+	}
+	i := 0
+	for k, t := range tokens {
+		if k > 0 && raw[k] != raw[k-1] {
+			if raw[k] {
+				buf.WriteString("\" + `")
+			} else {
+				buf.WriteString("` + \"")
+			}
+		}
+		if raw[k] {
+			buf.Write(b[i : i+t.size])
+		} else {
+			writeInterpreted(&buf, b[i:i+t.size], t.r)
+		}
+		i += t.size
+	}
+	if len(tokens) == 0 || !raw[len(raw)-1] {
+		buf.WriteByte('"')
+	} else {
+		buf.WriteByte('`')
+	}
+	return buf.String()
+}
+
+// writeInterpreted writes the token b, decoded as r, as it should
+// appear in an interpreted string literal.
+func writeInterpreted(buf *bytes.Buffer, b []byte, r rune) {
+	switch {
+	case r == utf8.RuneError && len(b) == 1:
+		fmt.Fprintf(buf, `\x%02x`, b[0])
+	case r == 0:
+		buf.WriteString(`\x00`)
+	case r == bom:
+		buf.WriteString(`\ufeff`)
+	case r == '\n':
+		buf.WriteString(`\n`)
+	case r == '"':
+		buf.WriteString(`\"`)
+	case r == '\\':
+		buf.WriteString(`\\`)
+	default:
+		buf.Write(b)
+	}
+}
+
+func encode(contents io.Reader) (string, error) {
+	b, err := ioutil.ReadAll(contents)
+	if err != nil {
+		return "", err
+	}
+	return Quote(b), nil
+}
+
+// NewSequential creates a new sequential cquoteembedder asset embedder.
+func NewSequential() goembed.AssetEmbedder {
+	e := goembed.NewSequentialEmbedder(encode, decode, imports[:])
+	e.SetName("cquote")
+	e.SetLiteral()
+	e.SetStream(stream, streamImports[:]...)
+	return e
+}
+
+// NewConcurrent creates a new concurrent cquoteembedder asset embedder.
+func NewConcurrent() goembed.AssetEmbedder {
+	e := goembed.NewConcurrentEmbedder(encode, decode, imports[:])
+	e.SetName("cquote")
+	e.SetLiteral()
+	e.SetStream(stream, streamImports[:]...)
+	return e
+}
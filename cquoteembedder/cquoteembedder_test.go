@@ -0,0 +1,169 @@
+package cquoteembedder
+
+import (
+	"bytes"
+	"go/scanner"
+	"go/token"
+	"strconv"
+	"testing"
+
+	"github.com/jeanfric/goembed"
+	"github.com/jeanfric/goembed/embedtesting"
+)
+
+// unquote evaluates a concatenation of string literals, as produced
+// by Quote, reporting any error found by the Go scanner.
+func unquote(t *testing.T, expr string) []byte {
+	var errs scanner.ErrorList
+	var s scanner.Scanner
+	fset := token.NewFileSet()
+	src := []byte(expr)
+	s.Init(fset.AddFile("", fset.Base(), len(src)), src, errs.Add, 0)
+
+	var out bytes.Buffer
+	wantString := true
+	for {
+		_, tok, lit := s.Scan()
+		if tok == token.EOF {
+			break
+		}
+		if tok == token.SEMICOLON && lit == "\n" {
+			// Automatically inserted at the end of the input.
+			continue
+		}
+		switch {
+		case wantString && tok == token.STRING:
+			v, err := strconv.Unquote(lit)
+			if err != nil {
+				t.Fatalf("%q: cannot unquote %q: %v", expr, lit, err)
+			}
+			out.WriteString(v)
+		case !wantString && tok == token.ADD:
+		default:
+			t.Fatalf("%q: unexpected token %v %q", expr, tok, lit)
+		}
+		wantString = !wantString
+	}
+	if errs.Len() > 0 {
+		t.Fatalf("%q: %v", expr, errs.Err())
+	}
+	if wantString {
+		t.Fatalf("%q: dangling operator", expr)
+	}
+	return out.Bytes()
+}
+
+func checkQuote(t *testing.T, b []byte) string {
+	q := Quote(b)
+	if got := unquote(t, q); !bytes.Equal(got, b) {
+		t.Fatalf("Quote(%q) = %q, which evaluates to %q", b, q, got)
+	}
+	if len(q) > len(strconv.Quote(string(b))) {
+		t.Fatalf("Quote(%q) = %q, longer than strconv.Quote", b, q)
+	}
+	return q
+}
+
+func TestQuote(t *testing.T) {
+	tests := []struct {
+		in, out string
+	}{
+		{"", `""`},
+		{"hello", `"hello"`},
+		{"tab\tdel\x7f", "\"tab\tdel\x7f\""},
+		{"\x00\xff", `"\x00\xff"`},
+		{"\ufeff", `"\ufeff"`},
+		{"\r\n", `"` + "\r" + `\n"`},
+		{"a\"b\"c\"d\"e\"f", "`a\"b\"c\"d\"e\"f`"},
+		{"`\n\n\n\n\n\n", "\"`\" + `\n\n\n\n\n\n`"},
+		{"\xe9t\xe9", `"\xe9t\xe9"`},
+		{"été", `"été"`},
+	}
+	for _, tt := range tests {
+		if q := checkQuote(t, []byte(tt.in)); q != tt.out {
+			t.Errorf("Quote(%q) = %q, want %q", tt.in, q, tt.out)
+		}
+	}
+}
+
+func TestQuoteTestAssets(t *testing.T) {
+	for _, a := range embedtesting.GetTestAssets() {
+		var b bytes.Buffer
+		if _, err := b.ReadFrom(a); err != nil {
+			t.Fatal(err)
+		}
+		checkQuote(t, b.Bytes())
+	}
+}
+
+func FuzzQuote(f *testing.F) {
+	f.Add([]byte(""))
+	f.Add([]byte("`\"\\\r\n\x00\ufeff\xff"))
+	f.Add([]byte("\n\n\n\n\n\n`\x80"))
+	f.Fuzz(func(t *testing.T, b []byte) {
+		checkQuote(t, b)
+	})
+}
+
+func BenchmarkSequentialEmbedder(b *testing.B) {
+	embedtesting.BenchmarkEmbedder(b, NewSequential())
+}
+
+func BenchmarkConcurrentEmbedder(b *testing.B) {
+	embedtesting.BenchmarkEmbedder(b, NewConcurrent())
+}
+
+func blob(ae goembed.AssetEmbedder) goembed.AssetEmbedder {
+	ae.(goembed.ConfigurableEmbedder).SetOptions(goembed.Options{Blob: true})
+	return ae
+}
+
+func TestBlobEmbedder(t *testing.T) {
+	embedtesting.TestEmbedder(t, blob(NewSequential()))
+	embedtesting.TestEmbedderLookup(t, blob(NewConcurrent()))
+	embedtesting.TestEmbedderAssets(t, blob(NewSequential()), map[string]string{
+		"/a":     "same",
+		"/b":     "same",
+		"/empty": "",
+	})
+}
+
+func hash(ae goembed.AssetEmbedder) goembed.AssetEmbedder {
+	ae.(goembed.ConfigurableEmbedder).SetOptions(goembed.Options{Hash: true})
+	return ae
+}
+
+func TestHashEmbedder(t *testing.T) {
+	embedtesting.TestEmbedder(t, hash(NewSequential()))
+	embedtesting.TestEmbedderLookup(t, hash(NewConcurrent()))
+	embedtesting.TestEmbedderAssets(t, hash(NewSequential()), map[string]string{
+		"/a":     "same",
+		"/b":     "same",
+		"/empty": "",
+	})
+	embedtesting.TestEmbedderAssets(t, hash(NewSequential()), map[string]string{})
+}
+
+func BenchmarkBlobLookup(b *testing.B) {
+	embedtesting.BenchmarkLookup(b, blob(NewSequential()))
+}
+
+func BenchmarkHashLookup(b *testing.B) {
+	embedtesting.BenchmarkLookup(b, hash(NewSequential()))
+}
+
+func BenchmarkBlobDecoder(b *testing.B) {
+	embedtesting.BenchmarkDecoder(b, blob(NewSequential()))
+}
+
+func TestAsmEmbedder(t *testing.T) {
+	embedtesting.TestEmbedderAsm(t, NewConcurrent().(goembed.ConfigurableEmbedder), goembed.Options{AsmThreshold: 0})
+}
+
+func TestBytesEmbedder(t *testing.T) {
+	embedtesting.TestEmbedderBytes(t, NewSequential().(goembed.ConfigurableEmbedder), goembed.Options{})
+}
+
+func TestOpenEmbedder(t *testing.T) {
+	embedtesting.TestEmbedderOpen(t, NewSequential().(goembed.ConfigurableEmbedder), goembed.Options{})
+}
@@ -0,0 +1,104 @@
+package goembed
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// A digestEntry holds the hexadecimal SHA-256 digest of the contents
+// of the asset named Key.
+type digestEntry struct {
+	Key, Digest string
+}
+
+// digestData completes g with the SHA-256 digests of the assets, if
+// the options ask so.  The digest of the bundle is the digest of the
+// list of the asset digests, sorted by key, in the format of the
+// sha256sum command.
+func (e *embedder) digestData(g *generatedFileData, distinct []*processedAsset) {
+	if !e.options.Digests && !e.options.Verify {
+		return
+	}
+	for _, a := range distinct {
+		d := hex.EncodeToString(a.Digest[:])
+		for _, k := range a.Keys {
+			g.Digests = append(g.Digests, &digestEntry{
+				Key:    k,
+				Digest: d,
+			})
+		}
+	}
+	sort.Slice(g.Digests, func(i, j int) bool {
+		return g.Digests[i].Key < g.Digests[j].Key
+	})
+	var list strings.Builder
+	for _, d := range g.Digests {
+		fmt.Fprintf(&list, "%s  %s\n", d.Digest, d.Key)
+	}
+	sum := sha256.Sum256([]byte(list.String()))
+	g.BundleDigest = hex.EncodeToString(sum[:])
+	g.Imports = mergeImports(g.Imports, "sort")
+	if e.options.Verify {
+		g.Verify = true
+		g.Imports = mergeImports(g.Imports, "crypto/sha256", "encoding/hex", "fmt")
+	}
+}
+
+// verifyTemplate checks the digests of the assets about to be
+// returned by a loading function, if verification is enabled.
+const verifyTemplate = `
+{{- if .Verify}}
+	if err := {{.FuncName}}Verify(assets); err != nil {
+		return nil, err
+	}
+{{- end}}`
+
+// digestTemplate declares the table of the asset digests, the digest
+// of the bundle, the function looking up the digest of an asset, and
+// the function verifying the assets returned by the loading function.
+const digestTemplate = `
+// {{.FuncName}}Digests holds the hexadecimal SHA-256 digest of each
+// asset, sorted by key.
+var {{.FuncName}}Digests = [...]struct {
+	key, digest string
+}{ {{- range $i, $v := .Digests}}
+	{ {{- printf "%q" $v.Key}}, {{printf "%q" $v.Digest}}},{{end}}
+}
+
+// {{.FuncName}}BundleDigest is the hexadecimal SHA-256 digest of all the
+// assets, computed over the lines "digest  key" of {{.FuncName}}Digests,
+// in the format of the sha256sum command.
+const {{.FuncName}}BundleDigest = {{printf "%q" .BundleDigest}}
+
+// {{.FuncName}}Digest returns the hexadecimal SHA-256 digest of the
+// asset named key, recorded when it was embedded, and whether it
+// exists.
+func {{.FuncName}}Digest(key string) (string, bool) {
+	i := sort.Search(len({{.FuncName}}Digests), func(i int) bool {
+		return {{.FuncName}}Digests[i].key >= key
+	})
+	if i == len({{.FuncName}}Digests) || {{.FuncName}}Digests[i].key != key {
+		return "", false
+	}
+	return {{.FuncName}}Digests[i].digest, true
+}
+{{if .Verify}}
+// {{.FuncName}}Verify checks that the SHA-256 digest of each of the
+// assets matches the digest recorded when it was embedded.
+func {{.FuncName}}Verify(assets map[string]{{.ValueType}}) error {
+	for _, d := range {{.FuncName}}Digests {
+		a, ok := assets[d.key]
+		if !ok {
+			return fmt.Errorf("%s: missing asset", d.key)
+		}
+		sum := sha256.Sum256([]byte(a))
+		if hex.EncodeToString(sum[:]) != d.digest {
+			return fmt.Errorf("%s: SHA-256 digest mismatch", d.key)
+		}
+	}
+	return nil
+}
+{{end}}`
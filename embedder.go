@@ -4,10 +4,15 @@ package goembed
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"text/template"
 
 	"github.com/jeanfric/goembed/countingwriter"
@@ -22,11 +27,21 @@ type Asset struct {
 }
 
 // A processedAsset represents an asset that has been encoded to a
-// representation suitable for embedding in a Go source file.
+// representation suitable for embedding in a Go source file.  Assets
+// with identical contents are encoded once: Keys lists the keys of
+// all of them, starting with the Key of the asset that was encoded.
 type processedAsset struct {
 	*Asset
+	Keys                  []string
+	Size                  int               // The size of the contents
+	Digest                [sha256.Size]byte // The SHA-256 digest of the contents
 	EncodedRepresentation string
 	Error                 error
+
+	// The data symbol holding the string representation of the
+	// asset, if it was moved to a Go assembly source file.
+	Symbol     string
+	SymbolSize int
 }
 
 // AssetEmbedder is an interface that wraps the basic AssetEmbed method.
@@ -52,11 +67,387 @@ type AssetEmbedder interface {
 // complete with information about the decoding function, loading
 // function, package name and list of imports.
 type generatedFileData struct {
-	PackageName string // The Go package name to use
-	FuncName    string // The name of the loading function
-	Imports     []string
-	Assets      []*processedAsset
-	DecodeFunc  string
+	PackageName   string // The Go package name to use
+	FuncName      string // The name of the loading function
+	Imports       []string
+	Assets        []*processedAsset
+	DecodeFunc    string
+	RawSuffix     string // The suffix of the raw accessor, if any
+	RawDecodeFunc string
+	Prelude       string // Statements preceding the decode function
+	Params        string // The parameters of the loading function
+	Args          string // The arguments passing on the parameters
+	Encoder       string // The name of the embedder, if any
+	MustFunc      string // The name of the loading function that panics
+	ValueType     string // The type of the values of the returned map
+	WrapBytes     bool   // Whether to convert the decoded strings to bytes
+	Table         bool   // Whether to decode the assets in a loop
+	Parallel      bool   // Whether to decode the assets concurrently
+	Aliases       []*alias
+	Symbols       []*processedAsset // Assets moved to data symbols
+
+	// In solid mode, the single asset holding the concatenation
+	// of all the assets, and the location of each of them.
+	Solid       *processedAsset
+	SolidLength int
+	Index       []*indexEntry
+
+	// In blob mode, the string literal holding the concatenation
+	// of all the assets, whose index is sorted by key, and the
+	// name of the lookup function.  In hash mode, the index is
+	// sorted by slot, and Displace is the displacement table of
+	// the perfect hash of the keys.
+	Blob       string
+	LookupFunc string
+	Hash       bool
+	Displace   []int32
+
+	// The name of the open function, if any, the stream function
+	// of the embedder, and the asset keys, sorted.
+	OpenFunc   string
+	StreamFunc string
+	Keys       []*openKey
+
+	// The digests of the assets, sorted by key, and of the
+	// bundle, and whether the loading function verifies them.
+	Digests      []*digestEntry
+	BundleDigest string
+	Verify       bool
+
+	// The constants naming the asset keys, sorted by key, and the
+	// name of the variable listing them.
+	Consts   []*assetConst
+	NamesVar string
+}
+
+// An alias names an asset whose contents are identical to those of
+// the asset named Of.
+type alias struct {
+	Key, Of string
+}
+
+// An indexEntry locates an asset in a solid bundle.
+type indexEntry struct {
+	Key            string
+	Offset, Length int
+}
+
+// A PrepareFunc prepares the encoding of a set of assets, given their
+// contents.  It returns Go statements that are inserted in the
+// loading function before the declaration of the decode function
+// (which can thus refer to the variables they declare), and the
+// function to use to encode each of the assets.
+//
+// A PrepareFunc allows an embedder to share data across all the
+// assets it embeds, such as a compression dictionary.
+type PrepareFunc func(contents [][]byte) (prelude string, encodeFunc func(io.Reader) (string, error), err error)
+
+// An embedder holds the configuration shared by the sequential and
+// concurrent embedders.
+type embedder struct {
+	encodeFunc    func(contents io.Reader) (string, error)
+	decodeFunc    string
+	imports       []string
+	rawSuffix     string
+	rawDecodeFunc string
+	prepareFunc   PrepareFunc
+	literal       bool
+	name          string
+	params        string
+	streamFunc    string
+	streamImports []string
+	options       Options
+}
+
+// SetRawAccessor makes the generated Go source file provide an
+// additional function, named after the loading function with suffix
+// appended, that has the same signature as the loading function but
+// returns the assets as decoded by rawDecodeFunc.  The loading
+// function then applies the embedder's decodeFunc to the values
+// returned by the raw accessor, rather than to the encoded strings.
+//
+// This is useful when the intermediate representation of the assets
+// is itself useful to the program, such as compressed data that can
+// be sent as is to a client.
+func (e *embedder) SetRawAccessor(suffix, rawDecodeFunc string) {
+	e.rawSuffix = suffix
+	e.rawDecodeFunc = rawDecodeFunc
+}
+
+// SetPrepare makes the embedder call prepareFunc with the contents
+// of all the assets before encoding them, and encode them using the
+// function it returns instead of the embedder's encodeFunc.
+func (e *embedder) SetPrepare(prepareFunc PrepareFunc) {
+	e.prepareFunc = prepareFunc
+}
+
+// SetParams declares the parameters of the loading function, and of
+// the open function (see Options), which follow the name of the asset
+// to open.  params is a Go parameter list, such as "key []byte".  The
+// prelude and decode function of the embedder can refer to the
+// parameters.
+func (e *embedder) SetParams(params string) {
+	e.params = params
+}
+
+// SetLiteral declares that the string representation produced by the
+// embedder's encodeFunc is a Go string literal holding the contents of
+// the asset, which the decodeFunc returns unchanged.  Only literal
+// embedders support the blob layout (see Options).
+func (e *embedder) SetLiteral() {
+	e.literal = true
+}
+
+// prepare reads the assets, and returns the distinct assets to
+// encode, the statements preceding the decode function, and the
+// function to encode the assets with.  Assets with the same contents
+// as an asset that precedes them are not encoded again; each of them
+// is reported to the log set in the options, if any.
+func (e *embedder) prepare(assets []*Asset) ([]*processedAsset, string, func(io.Reader) (string, error), error) {
+	// The assets are read once to find duplicates, so they need
+	// to be replaced by assets that can be read again.
+	var contents [][]byte
+	var distinct []*processedAsset
+	seen := make(map[[sha256.Size]byte]*processedAsset)
+	for _, a := range assets {
+		b, err := ioutil.ReadAll(a)
+		if err != nil {
+			return nil, "", nil, err
+		}
+		sum := sha256.Sum256(b)
+		if p, ok := seen[sum]; ok {
+			p.Keys = append(p.Keys, a.Key)
+			if e.options.Log != nil {
+				fmt.Fprintf(e.options.Log, "%s: duplicate of %s, %d bytes saved\n", a.Key, p.Key, len(b))
+			}
+			continue
+		}
+		p := &processedAsset{
+			Asset: &Asset{
+				Reader: bytes.NewReader(b),
+				Key:    a.Key,
+			},
+			Keys:   []string{a.Key},
+			Size:   len(b),
+			Digest: sum,
+		}
+		seen[sum] = p
+		contents = append(contents, b)
+		distinct = append(distinct, p)
+	}
+
+	if e.prepareFunc == nil {
+		return distinct, "", e.encodeFunc, nil
+	}
+	prelude, encodeFunc, err := e.prepareFunc(contents)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	return distinct, prelude, encodeFunc, nil
+}
+
+// fileData returns the information needed to produce a Go source file
+// from a set of processed assets.
+func (e *embedder) fileData(packageName, funcName, prelude string, assets []*processedAsset) *generatedFileData {
+	var aliases []*alias
+	var symbols []*processedAsset
+	for _, a := range assets {
+		if a.Symbol != "" {
+			symbols = append(symbols, a)
+		}
+		for _, k := range a.Keys[1:] {
+			aliases = append(aliases, &alias{
+				Key: k,
+				Of:  a.Key,
+			})
+		}
+	}
+	imports := mergeImports(e.imports, "fmt")
+	g := &generatedFileData{
+		PackageName:   packageName,
+		FuncName:      funcName,
+		Imports:       imports,
+		Assets:        assets,
+		DecodeFunc:    e.decodeFunc,
+		RawSuffix:     e.rawSuffix,
+		RawDecodeFunc: e.rawDecodeFunc,
+		Prelude:       prelude,
+		Params:        e.params,
+		Args:          paramArgs(e.params),
+		Encoder:       e.name,
+		MustFunc:      mustName(funcName),
+		Table:         e.options.Table,
+		Aliases:       aliases,
+		Symbols:       symbols,
+		ValueType:     "string",
+	}
+	if e.options.Bytes {
+		g.ValueType = "[]byte"
+		if f, ok := bytesDecodeFunc(e.decodeFunc); ok {
+			g.DecodeFunc = f
+		} else {
+			g.WrapBytes = true
+		}
+	}
+	return g
+}
+
+// concatenate reads the distinct assets into a single bundle, and
+// returns it together with the location of each asset key in it.
+// Assets with identical contents share the same location.
+func concatenate(distinct []*processedAsset) (*bytes.Buffer, []*indexEntry, error) {
+	var bundle bytes.Buffer
+	var index []*indexEntry
+	for _, p := range distinct {
+		offset := bundle.Len()
+		if _, err := bundle.ReadFrom(p); err != nil {
+			return nil, nil, err
+		}
+		for _, k := range p.Keys {
+			index = append(index, &indexEntry{
+				Key:    k,
+				Offset: offset,
+				Length: bundle.Len() - offset,
+			})
+		}
+	}
+	return &bundle, index, nil
+}
+
+// embedSolid outputs a Go source file containing the assets,
+// concatenated and encoded as a single asset.
+func (e *embedder) embedSolid(dst io.Writer, assets []*Asset, packageName, funcName string) (int, error) {
+	if e.rawSuffix != "" {
+		return 0, errors.New("goembed: solid mode is not supported by embedders with a raw accessor")
+	}
+	if e.options.Blob || e.options.Hash {
+		return 0, errors.New("goembed: solid mode is incompatible with blob and hash modes")
+	}
+	if e.options.Open != "" {
+		return 0, errors.New("goembed: solid mode does not support the open function")
+	}
+	distinct, prelude, encodeFunc, err := e.prepare(assets)
+	if err != nil {
+		return 0, err
+	}
+	bundle, index, err := concatenate(distinct)
+	if err != nil {
+		return 0, err
+	}
+	length := bundle.Len()
+	solid := &Asset{
+		Reader: bundle,
+	}
+	r, err := encodeFunc(solid)
+	if err != nil {
+		return 0, err
+	}
+
+	g := e.fileData(packageName, funcName, prelude, nil)
+	g.Imports = mergeImports(g.Imports, "errors")
+	g.Solid = &processedAsset{
+		Asset:                 solid,
+		EncodedRepresentation: r,
+	}
+	if err := e.writeAsm(funcName, []*processedAsset{g.Solid}); err != nil {
+		return 0, err
+	}
+	if g.Solid.Symbol != "" {
+		g.Symbols = []*processedAsset{g.Solid}
+	}
+	g.SolidLength = length
+	g.Index = index
+	e.digestData(g, distinct)
+	if err := e.constData(g, distinct); err != nil {
+		return 0, err
+	}
+	return generateEmbedFile(dst, g)
+}
+
+// embedBlob outputs a Go source file containing the assets,
+// concatenated in a single string constant, in blob or hash mode.
+func (e *embedder) embedBlob(dst io.Writer, assets []*Asset, packageName, funcName string) (int, error) {
+	if !e.literal {
+		return 0, errors.New("goembed: blob and hash modes are only supported by embedders producing string literals")
+	}
+	if e.options.Asm != nil {
+		return 0, errors.New("goembed: blob and hash modes do not support assembly output")
+	}
+	if e.options.Bytes {
+		return 0, errors.New("goembed: blob and hash modes do not support byte slice values")
+	}
+	if e.options.Open != "" {
+		return 0, errors.New("goembed: blob and hash modes do not support the open function")
+	}
+	distinct, _, encodeFunc, err := e.prepare(assets)
+	if err != nil {
+		return 0, err
+	}
+	bundle, index, err := concatenate(distinct)
+	if err != nil {
+		return 0, err
+	}
+	r, err := encodeFunc(&Asset{
+		Reader: bundle,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	// The decode function is not needed, since the assets are
+	// stored as is.
+	g := &generatedFileData{
+		PackageName: packageName,
+		FuncName:    funcName,
+		Index:       index,
+		Blob:        r,
+		LookupFunc:  e.options.Lookup,
+		MustFunc:    mustName(funcName),
+		ValueType:   "string",
+	}
+	if g.LookupFunc == "" {
+		g.LookupFunc = funcName + "Lookup"
+	}
+	if e.options.Hash {
+		keys := make([]string, len(index))
+		for i, a := range index {
+			keys[i] = a.Key
+		}
+		displace, slots, err := perfectHash(keys)
+		if err != nil {
+			return 0, err
+		}
+		g.Index = make([]*indexEntry, len(index))
+		for i, a := range index {
+			g.Index[slots[i]] = a
+		}
+		g.Hash = true
+		g.Displace = displace
+	} else {
+		sort.Slice(index, func(i, j int) bool {
+			return index[i].Key < index[j].Key
+		})
+		g.Imports = []string{"sort"}
+	}
+	e.digestData(g, distinct)
+	if err := e.constData(g, distinct); err != nil {
+		return 0, err
+	}
+	return generateEmbedFile(dst, g)
+}
+
+// mergeImports returns the sorted union of imports and extra.
+func mergeImports(imports []string, extra ...string) []string {
+	seen := make(map[string]bool)
+	var merged []string
+	for _, v := range append(append([]string(nil), imports...), extra...) {
+		if !seen[v] {
+			seen[v] = true
+			merged = append(merged, v)
+		}
+	}
+	sort.Strings(merged)
+	return merged
 }
 
 // FindAssets walks a directory recursively and generates a list of
@@ -98,6 +489,104 @@ func FindAssets(rootPath string) ([]*Asset, error) {
 	return assetList, nil
 }
 
+// decodeTemplate declares the decode function, preceded by the
+// prelude statements, if any.  When the values of the returned map
+// are byte slices but the decode function of the embedder cannot be
+// rewritten to return byte slices, the decode function converts the
+// strings it returns.
+const decodeTemplate = `{{if .Prelude}}	{{.Prelude}}
+{{end}}{{if .WrapBytes}}	decodeString := {{.DecodeFunc}}
+	decode := func(s string) ([]byte, error) {
+		a, err := decodeString(s)
+		return []byte(a), err
+	}
+{{else}}	decode := {{.DecodeFunc}}
+{{end}}`
+
+// rawAliasesTemplate completes the assets decoded by the loading
+// function of an embedder with a raw accessor with their aliases.
+const rawAliasesTemplate = `
+{{- if .Aliases}}
+	for _, a := range aliases {
+{{- if eq .ValueType "[]byte"}}
+		assets[a.key] = append([]byte(nil), assets[a.of]...)
+{{- else}}
+		assets[a.key] = assets[a.of]
+{{- end}}
+	}
+{{- end}}`
+
+// assetsTemplate is the body of a function that decodes each embedded
+// asset using the decode function declared before it, and returns the
+// results in a map.  The assets are decoded either one after the
+// other, or in a loop over a table of the encoded assets.
+const assetsTemplate = `{{if .Table}}
+	encoded := [...]struct {
+		key, s string
+	}{ {{- range $i, $v := .Assets}}
+		{ {{- printf "%q" $v.Key}}, {{$v.Expr}}},{{end}}
+	}
+{{- if .Parallel}}
+	decoded := make([]{{.ValueType}}, len(encoded))
+	err := {{.FuncName}}Parallel(len(encoded), func(i int) error {
+		a, err := decode(encoded[i].s)
+		if err != nil {
+			return {{.FuncName}}Error(encoded[i].key, err)
+		}
+		decoded[i] = a
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	assets := make(map[string]{{.ValueType}}, len(encoded))
+	for i, e := range encoded {
+		assets[e.key] = decoded[i]
+	}
+{{- else}}
+	assets := make(map[string]{{.ValueType}}, len(encoded))
+	for _, e := range encoded {
+		a, err := decode(e.s)
+		if err != nil {
+			return nil, {{.FuncName}}Error(e.key, err)
+		}
+		assets[e.key] = a
+	}
+{{- end}}
+{{- if .Aliases}}
+	aliases := [...]struct {
+		key, of string
+	}{ {{- range $i, $v := .Aliases}}
+		{ {{- printf "%q" $v.Key}}, {{printf "%q" $v.Of}}},{{end}}
+	}
+	for _, a := range aliases {
+{{- if eq .ValueType "[]byte"}}
+		// Each key gets its own copy, which can be written to.
+		assets[a.key] = append([]byte(nil), assets[a.of]...)
+{{- else}}
+		assets[a.key] = assets[a.of]
+{{- end}}
+	}
+{{- end}}` + verifyTemplate + `
+	return assets, nil
+}
+{{else}}
+	var a {{.ValueType}}
+	var err error
+	assets := make(map[string]{{.ValueType}})
+{{range $i, $v := .Assets}}
+	a, err = decode({{$v.Expr}})
+	if err != nil {
+		return nil, {{$.FuncName}}Error({{printf "%q" $v.Key}}, err)
+	}
+{{- range $j, $k := $v.Keys}}
+	assets[{{printf "%q" $k}}] = {{if and $j (eq $.ValueType "[]byte")}}append([]byte(nil), a...){{else}}a{{end}}
+{{- end}}
+{{end}}` + verifyTemplate + `
+	return assets, nil
+}
+{{end}}`
+
 func generateEmbedFile(dst io.Writer, data *generatedFileData) (int, error) {
 	// TODO: using templates is probably a tad overkill here, but
 	// it makes the code more pleasant to read.
@@ -112,23 +601,223 @@ import ({{range $v := .Imports}}
 `
 	}
 
-	outputTemplate += `
-func {{.FuncName}}() (map[string]string, error) {
-	decode := {{.DecodeFunc}}
+	// The data symbols are defined in the Go assembly source
+	// file.
+	outputTemplate += `{{range .Symbols}}
+var {{.Symbol}} [{{.SymbolSize}}]byte
+{{end}}` + constsTemplate
 
-	var a string
-	var err error
-	assets := make(map[string]string)
-{{range $i, $v := .Assets}}
-	a, err = decode({{$v.EncodedRepresentation}})
+	// With an open function, the encoded assets are in package
+	// level tables, shared with the loading function.
+	assets := assetsTemplate
+	if data.OpenFunc != "" {
+		outputTemplate += openTablesTemplate
+		assets = openAssetsTemplate
+	}
+
+	if data.Blob != "" {
+		// The assets are substrings of the blob, located
+		// using an index sorted by key, or by perfect hash slot.
+		outputTemplate += `
+const {{.FuncName}}Blob = {{.Blob}}
+
+var {{.FuncName}}Index = [...]struct {
+	key            string
+	offset, length int
+}{ {{- range $i, $v := .Index}}
+	{ {{- printf "%q" $v.Key}}, {{$v.Offset}}, {{$v.Length}}},{{end}}
+}
+{{if .Hash}}
+var {{.FuncName}}Displace = [...]int32{ {{- range $i, $v := .Displace}}
+	{{$v}},{{end}}
+}
+
+// {{.LookupFunc}} returns the contents of the asset named key, and
+// whether it exists.  The index entry of the asset is found using a
+// perfect hash of the asset keys.
+func {{.LookupFunc}}(key string) (string, bool) {
+	n := uint32(len({{.FuncName}}Index))
+	if n == 0 {
+		return "", false
+	}
+	h := uint64(14695981039346656037)
+	for i := 0; i < len(key); i++ {
+		h ^= uint64(key[i])
+		h *= 1099511628211
+	}
+	var i uint32
+	if d := {{.FuncName}}Displace[{{.FuncName}}Mix(h, 0)%n]; d < 0 {
+		i = uint32(-d - 1)
+	} else {
+		i = {{.FuncName}}Mix(h, uint32(d)) % n
+	}
+	a := {{.FuncName}}Index[i]
+	if a.key != key {
+		return "", false
+	}
+	return {{.FuncName}}Blob[a.offset : a.offset+a.length], true
+}
+
+func {{.FuncName}}Mix(h uint64, seed uint32) uint32 {
+	h ^= uint64(seed) * 0x9e3779b97f4a7c15
+	h ^= h >> 30
+	h *= 0xbf58476d1ce4e5b9
+	h ^= h >> 27
+	h *= 0x94d049bb133111eb
+	h ^= h >> 31
+	return uint32(h)
+}
+{{else}}
+// {{.LookupFunc}} returns the contents of the asset named key, and
+// whether it exists.
+func {{.LookupFunc}}(key string) (string, bool) {
+	i := sort.Search(len({{.FuncName}}Index), func(i int) bool {
+		return {{.FuncName}}Index[i].key >= key
+	})
+	if i == len({{.FuncName}}Index) || {{.FuncName}}Index[i].key != key {
+		return "", false
+	}
+	a := {{.FuncName}}Index[i]
+	return {{.FuncName}}Blob[a.offset : a.offset+a.length], true
+}
+{{end}}
+func {{.FuncName}}() (map[string]string, error) {
+	assets := make(map[string]string, len({{.FuncName}}Index))
+	for _, a := range {{.FuncName}}Index {
+		assets[a.key] = {{.FuncName}}Blob[a.offset : a.offset+a.length]
+	}` + verifyTemplate + `
+	return assets, nil
+}
+`
+	} else if data.Solid != nil {
+		// The whole bundle is decoded at once, and each asset
+		// is a substring (or subslice) of it.
+		outputTemplate += `
+func {{.FuncName}}({{.Params}}) (map[string]{{.ValueType}}, error) {
+` + decodeTemplate + `
+	bundle, err := decode({{.Solid.Expr}})
+	if err != nil {
+		return nil, {{.FuncName}}Error("bundle", err)
+	}
+	if len(bundle) != {{.SolidLength}} {
+		return nil, errors.New("{{.FuncName}}: unexpected bundle length")
+	}
+	index := []struct {
+		key            string
+		offset, length int
+	}{ {{- range $i, $v := .Index}}
+		{ {{- printf "%q" $v.Key}}, {{$v.Offset}}, {{$v.Length}}},{{end}}
+	}
+	assets := make(map[string]{{.ValueType}}, len(index))
+{{- if eq .ValueType "[]byte"}}
+	for i, a := range index {
+		v := bundle[a.offset : a.offset+a.length : a.offset+a.length]
+		// The keys of identical assets follow each other, and
+		// each of them gets its own copy, which can be written to.
+		if i > 0 && a.length > 0 && index[i-1].offset == a.offset && index[i-1].length == a.length {
+			v = append([]byte(nil), v...)
+		}
+		assets[a.key] = v
+	}
+{{- else}}
+	for _, a := range index {
+		assets[a.key] = bundle[a.offset : a.offset+a.length]
+	}
+{{- end}}` + verifyTemplate + `
+	return assets, nil
+}
+`
+	} else if data.RawSuffix != "" {
+		// The raw accessor holds the encoded assets, and the
+		// loading function decodes the values it returns.
+		outputTemplate += `
+func {{.FuncName}}{{.RawSuffix}}() (map[string]string, error) {
+	decode := {{.RawDecodeFunc}}
+` + strings.NewReplacer("{{.ValueType}}", "string", `eq .ValueType "[]byte"`, "false", `eq $.ValueType "[]byte"`, "false", verifyTemplate, "").Replace(assets) + `
+func {{.FuncName}}({{.Params}}) (map[string]{{.ValueType}}, error) {
+` + decodeTemplate + `
+	raw, err := {{.FuncName}}{{.RawSuffix}}()
 	if err != nil {
 		return nil, err
 	}
-	assets[{{printf "%q" $v.Key}}] = a
-{{end}}
+{{- if .Aliases}}
+	// The assets with the same contents as another one are not
+	// decoded again.
+	aliases := [...]struct {
+		key, of string
+	}{ {{- range $i, $v := .Aliases}}
+		{ {{- printf "%q" $v.Key}}, {{printf "%q" $v.Of}}},{{end}}
+	}
+	isAlias := make(map[string]bool, len(aliases))
+	for _, a := range aliases {
+		isAlias[a.key] = true
+	}
+{{- end}}
+{{- if .Parallel}}
+	keys := make([]string, 0, len(raw))
+	for k := range raw {
+{{- if .Aliases}}
+		if isAlias[k] {
+			continue
+		}
+{{- end}}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	decoded := make([]{{.ValueType}}, len(keys))
+	err = {{.FuncName}}Parallel(len(keys), func(i int) error {
+		a, err := decode(raw[keys[i]])
+		if err != nil {
+			return {{.FuncName}}Error(keys[i], err)
+		}
+		decoded[i] = a
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	assets := make(map[string]{{.ValueType}}, len(raw))
+	for i, k := range keys {
+		assets[k] = decoded[i]
+	}` + rawAliasesTemplate + verifyTemplate + `
 	return assets, nil
 }
+{{- else}}
+	assets := make(map[string]{{.ValueType}}, len(raw))
+	for k, v := range raw {
+{{- if .Aliases}}
+		if isAlias[k] {
+			continue
+		}
+{{- end}}
+		a, err := decode(v)
+		if err != nil {
+			return nil, {{.FuncName}}Error(k, err)
+		}
+		assets[k] = a
+	}` + rawAliasesTemplate + verifyTemplate + `
+	return assets, nil
+}
+{{- end}}
 `
+	} else {
+		outputTemplate += `
+func {{.FuncName}}({{.Params}}) (map[string]{{.ValueType}}, error) {
+` + decodeTemplate + assets
+	}
+	if data.OpenFunc != "" {
+		outputTemplate += openFuncTemplate
+	}
+	if data.Digests != nil {
+		outputTemplate += digestTemplate
+	}
+	if data.Blob == "" {
+		outputTemplate += errorTemplate
+	}
+	outputTemplate += mustTemplate
+	if data.Parallel {
+		outputTemplate += parallelTemplate
+	}
 	t := template.Must(template.New("").Parse(outputTemplate))
 
 	// The counting writer will enable us to report how many bytes
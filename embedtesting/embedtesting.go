@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"sort"
 	"strings"
 	"testing"
 
@@ -26,13 +27,20 @@ func GetTestAssets() []*goembed.Asset {
 	return AssetsFromMap(testAssets)
 }
 
+// GetTestAsset returns the contents of the test asset named key.
+func GetTestAsset(key string) string {
+	return testAssets[key]
+}
+
 func GetBenchAssets() []*goembed.Asset {
 	benchAssets := make(map[string]string)
 
-	// Amplify the size of the test data
+	// Amplify the size of the test data.  Appending the copy
+	// number to the contents keeps the copies from being
+	// embedded only once.
 	for k, v := range testAssets {
 		for i := 0; i < 100; i++ {
-			benchAssets[fmt.Sprintf("%i/%s", i, k)] = v
+			benchAssets[fmt.Sprintf("%d/%s", i, k)] = fmt.Sprintf("%s%d", v, i)
 		}
 	}
 
@@ -40,10 +48,15 @@ func GetBenchAssets() []*goembed.Asset {
 }
 
 func AssetsFromMap(m map[string]string) []*goembed.Asset {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
 	assetList := make([]*goembed.Asset, 0, 0)
-	for k, v := range m {
+	for _, k := range keys {
 		assetList = append(assetList, &goembed.Asset{
-			Reader: strings.NewReader(v),
+			Reader: strings.NewReader(m[k]),
 			Key:    k,
 		})
 	}
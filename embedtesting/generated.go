@@ -0,0 +1,1053 @@
+package embedtesting
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"text/template"
+
+	"github.com/jeanfric/goembed"
+)
+
+// mainProgram loads the embedded assets the number of times given as
+// its argument, as strings or byte slices, then prints the key, size
+// and SHA-256 digest of each asset.
+const mainProgram = `package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+func main() {
+	n, err := strconv.Atoi(os.Args[1])
+	if err != nil {
+		panic(err)
+	}
+	// The assets are loaded at least once.
+	assets, err := loadAssets()
+	for i := 1; i < n && err == nil; i++ {
+		assets, err = loadAssets()
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	for k, v := range assets {
+		fmt.Printf("%q\t%d\t%x\n", k, len(v), sha256.Sum256([]byte(v)))
+	}
+}
+`
+
+// lookupProgram checks that the lookup function returns the same
+// assets as the loading function, and that it does not find assets
+// that do not exist.  It prints the problems it finds.
+const lookupProgram = `package main
+
+import (
+	"fmt"
+	"os"
+)
+
+func main() {
+	assets, err := loadAssets()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	for k, v := range assets {
+		if got, ok := loadAssetsLookup(k); !ok || got != v {
+			fmt.Printf("%q: lookup mismatch\n", k)
+		}
+	}
+	for _, k := range []string{"", "/", "/\xff", "/nonexistent", "~"} {
+		if _, ok := assets[k]; ok {
+			continue
+		}
+		if _, ok := loadAssetsLookup(k); ok {
+			fmt.Printf("%q: unexpected lookup success\n", k)
+		}
+	}
+}
+`
+
+// openProgram checks that the open function reads the same assets as
+// the loading function, from various positions reached by seeking
+// forward and backward, or using ReadAt, and that it does not open
+// assets that do not exist.  It prints the problems it finds.
+const openProgram = `package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+func main() {
+	assets, err := loadAssets()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	for k, v := range assets {
+		f, err := openAsset(k)
+		if err != nil {
+			fmt.Printf("%q: %v\n", k, err)
+			continue
+		}
+		b, err := io.ReadAll(f)
+		if err != nil || string(b) != string(v) {
+			fmt.Printf("%q: read mismatch (%v)\n", k, err)
+		}
+		size := int64(len(v))
+		for _, off := range []int64{size, 0, 1, 2, 3, 4, size / 2, size/2 + 1, size - 1, 2, size + 1} {
+			if off < 0 {
+				continue
+			}
+			if _, err := f.Seek(off, io.SeekStart); err != nil {
+				fmt.Printf("%q: seek to %d: %v\n", k, off, err)
+				continue
+			}
+			// Read a few bytes, then seek forward
+			// relative to the current position.
+			want := ""
+			if off < size {
+				want = string(v[off:])
+			}
+			if len(want) > 3 {
+				want = want[:3]
+			}
+			p := make([]byte, 3)
+			n, err := io.ReadFull(f, p)
+			if string(p[:n]) != want {
+				fmt.Printf("%q: read at %d mismatch (%v)\n", k, off, err)
+				continue
+			}
+			pos, err := f.Seek(int64(n)+5, io.SeekCurrent)
+			if err != nil {
+				fmt.Printf("%q: seek from %d: %v\n", k, off, err)
+				continue
+			}
+			b, err := io.ReadAll(f)
+			if err != nil || pos > size && len(b) > 0 || pos <= size && string(b) != string(v[pos:]) {
+				fmt.Printf("%q: read from %d mismatch (%v)\n", k, pos, err)
+			}
+		}
+		for _, off := range []int64{size - 100, 0, size / 3, size - 1, size, size + 1} {
+			if off < 0 {
+				continue
+			}
+			p := make([]byte, 50)
+			n, err := f.(io.ReaderAt).ReadAt(p, off)
+			want := ""
+			if off < size {
+				want = string(v[off:])
+			}
+			if len(want) > len(p) {
+				want = want[:len(p)]
+			}
+			if string(p[:n]) != want || n < len(p) && err != io.EOF {
+				fmt.Printf("%q: read at %d mismatch (%v)\n", k, off, err)
+			}
+		}
+		if pos, err := f.Seek(-1, io.SeekEnd); size > 0 && (err != nil || pos != size-1) {
+			fmt.Printf("%q: seek from end: %d, %v\n", k, pos, err)
+		}
+		if _, err := f.Seek(-1, io.SeekStart); err == nil {
+			fmt.Printf("%q: unexpected seek success\n", k)
+		}
+		if err := f.Close(); err != nil {
+			fmt.Printf("%q: close: %v\n", k, err)
+		}
+	}
+	for _, k := range []string{"", "/", "/\xff", "/nonexistent", "~"} {
+		if _, ok := assets[k]; ok {
+			continue
+		}
+		if _, err := openAsset(k); !errors.Is(err, os.ErrNotExist) {
+			fmt.Printf("%q: unexpected open result %v\n", k, err)
+		}
+	}
+}
+`
+
+// digestProgram loads the embedded assets, then prints the key and
+// recorded digest of each asset, and the recorded digest of the
+// bundle, keyed by an empty key.  It prints the problems it finds.
+const digestProgram = `package main
+
+import (
+	"fmt"
+	"os"
+)
+
+func main() {
+	assets, err := loadAssets()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	for k := range assets {
+		d, ok := loadAssetsDigest(k)
+		if !ok {
+			fmt.Printf("%q: no digest\n", k)
+		}
+		fmt.Printf("%q\t%s\n", k, d)
+	}
+	if _, ok := loadAssetsDigest("/nonexistent"); ok {
+		fmt.Println("unexpected digest of nonexistent asset")
+	}
+	fmt.Printf("%q\t%s\n", "", loadAssetsBundleDigest)
+}
+`
+
+// errorProgram prints the error returned by the loading function, then
+// the value the panicking loading function panics with.
+const errorProgram = `package main
+
+import (
+	"fmt"
+)
+
+func main() {
+	_, err := loadAssets()
+	fmt.Println(err)
+	defer func() {
+		fmt.Println(recover())
+	}()
+	mustLoadAssets()
+}
+`
+
+// constsProgram checks that the constants named after the keys of
+// the assets, listed in .Consts, hold these keys, and that assetNames
+// lists the keys of the loaded assets, sorted.  It prints the problems
+// it finds.
+const constsProgram = `package main
+
+import (
+	"fmt"
+)
+
+func main() {
+	assets := mustLoadAssets()
+	for name, key := range map[string]string{ {{- range $name, $key := .Consts}}
+		{{printf "%q" $name}}: {{$name}},{{end}}
+	} {
+		if want := map[string]string{ {{- range $name, $key := .Consts}}
+			{{printf "%q" $name}}: {{printf "%q" $key}},{{end}}
+		}[name]; key != want {
+			fmt.Printf("%s: got %q, want %q\n", name, key, want)
+		}
+	}
+	if len(assetNames) != len(assets) {
+		fmt.Printf("got %d names, want %d\n", len(assetNames), len(assets))
+	}
+	for i, k := range assetNames {
+		if _, ok := assets[k]; !ok {
+			fmt.Printf("%q: no such asset\n", k)
+		}
+		if i > 0 && assetNames[i-1] >= k {
+			fmt.Printf("%q: names not sorted\n", k)
+		}
+	}
+}
+`
+
+// mutateProgram writes to each of the byte slices returned by the
+// loading function in turn, and checks that the other ones are left
+// untouched.  It prints the problems it finds.
+const mutateProgram = `package main
+
+import (
+	"fmt"
+	"os"
+)
+
+func main() {
+	assets, err := loadAssets()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	want := make(map[string]string, len(assets))
+	for k, v := range assets {
+		want[k] = string(v)
+	}
+	for k, v := range assets {
+		if len(v) == 0 {
+			continue
+		}
+		v[0] ^= 0xff
+		for l, w := range assets {
+			if l != k && string(w) != want[l] {
+				fmt.Printf("%q: changed by a write to %q\n", l, k)
+			}
+		}
+		v[0] ^= 0xff
+	}
+}
+`
+
+// lookupBenchProgram looks up the embedded assets the number of times
+// given as its second argument, cycling through the asset keys,
+// either in the map returned by the loading function, or using the
+// lookup function, depending on its first argument.  It prints the
+// time spent loading the assets before the first lookup, and the time
+// spent looking them up, in nanoseconds.
+const lookupBenchProgram = `package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+func main() {
+	n, err := strconv.Atoi(os.Args[2])
+	if err != nil {
+		panic(err)
+	}
+	assets, err := loadAssets()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	keys := make([]string, 0, len(assets))
+	for k := range assets {
+		keys = append(keys, k)
+	}
+
+	var setup time.Duration
+	lookup := loadAssetsLookup
+	if os.Args[1] == "map" {
+		start := time.Now()
+		assets, err = loadAssets()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		setup = time.Since(start)
+		lookup = func(k string) (string, bool) {
+			v, ok := assets[k]
+			return v, ok
+		}
+	}
+	size := 0
+	start := time.Now()
+	for i := 0; i < n; i++ {
+		v, ok := lookup(keys[i%len(keys)])
+		if !ok {
+			panic("lookup failed")
+		}
+		size += len(v)
+	}
+	fmt.Println(setup.Nanoseconds(), time.Since(start).Nanoseconds(), size)
+}
+`
+
+// A generatedProgram is a program embedding a set of assets, built
+// from the Go source file produced by an asset embedder.
+type generatedProgram struct {
+	dir    string
+	binary string
+}
+
+// goCommand returns a command running the go tool in dir, in module
+// mode, regardless of the settings of the calling environment.
+func goCommand(dir string, args ...string) *exec.Cmd {
+	cmd := exec.Command(filepath.Join(runtime.GOROOT(), "bin", "go"), args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GO111MODULE=on", "GOFLAGS=")
+	return cmd
+}
+
+// buildProgram generates a Go source file embedding assets using ae,
+// and builds it together with mainProgram.
+// The caller must remove the program once done.
+func buildProgram(ae goembed.AssetEmbedder, assets []*goembed.Asset) (*generatedProgram, error) {
+	return buildMain(ae, assets, mainProgram, nil)
+}
+
+// buildMain generates a Go source file embedding assets using ae, and
+// builds it together with the main source file mainSrc.  If o is not
+// nil, ae is configured with o, and with a Go assembly source file.
+// The caller must remove the program once done.
+func buildMain(ae goembed.AssetEmbedder, assets []*goembed.Asset, mainSrc string, o *goembed.Options) (*generatedProgram, error) {
+	dir, err := ioutil.TempDir(os.TempDir(), "embedtesting")
+	if err != nil {
+		return nil, err
+	}
+	p := &generatedProgram{
+		dir:    dir,
+		binary: filepath.Join(dir, "loader"),
+	}
+	files := map[string]string{
+		"go.mod":  "module loader\n",
+		"main.go": mainSrc,
+	}
+	for name, contents := range files {
+		err = ioutil.WriteFile(filepath.Join(dir, name), []byte(contents), 0644)
+		if err != nil {
+			p.remove()
+			return nil, err
+		}
+	}
+	if o != nil {
+		asm, err := os.Create(filepath.Join(dir, "assets.generated.s"))
+		if err != nil {
+			p.remove()
+			return nil, err
+		}
+		defer asm.Close()
+		o.Asm = asm
+		ae.(goembed.ConfigurableEmbedder).SetOptions(*o)
+	}
+	f, err := os.Create(filepath.Join(dir, "assets.generated.go"))
+	if err != nil {
+		p.remove()
+		return nil, err
+	}
+	_, err = ae.AssetEmbed(f, assets, "main", "loadAssets")
+	f.Close()
+	if err != nil {
+		p.remove()
+		return nil, err
+	}
+	out, err := goCommand(dir, "build", "-o", p.binary).CombinedOutput()
+	if err != nil {
+		p.remove()
+		return nil, fmt.Errorf("go build: %v\n%s", err, out)
+	}
+	return p, nil
+}
+
+// run runs the program, loading the assets n times, and returns the
+// digests of the loaded assets, keyed by asset key.
+func (p *generatedProgram) run(n int) (map[string]string, error) {
+	cmd := exec.Command(p.binary, strconv.Itoa(n))
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("%v: %s", err, stderr.String())
+	}
+	digests := make(map[string]string)
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		if line == "" {
+			continue
+		}
+		fields := strings.SplitN(line, "\t", 2)
+		key, err := strconv.Unquote(fields[0])
+		if err != nil {
+			return nil, err
+		}
+		digests[key] = fields[1]
+	}
+	return digests, nil
+}
+
+func (p *generatedProgram) remove() {
+	os.RemoveAll(p.dir)
+}
+
+// digests returns the size and SHA-256 digest of each asset of m, in
+// the format printed by the generated programs.
+func digests(m map[string]string) map[string]string {
+	d := make(map[string]string)
+	for k, v := range m {
+		d[k] = fmt.Sprintf("%d\t%x", len(v), sha256.Sum256([]byte(v)))
+	}
+	return d
+}
+
+// TestEmbedder checks that the Go source file generated by ae
+// compiles, and that its loading function returns exact replicas of
+// the test assets.
+func TestEmbedder(t *testing.T, ae goembed.AssetEmbedder) {
+	TestEmbedderAssets(t, ae, testAssets)
+}
+
+// TestEmbedderAssets is like TestEmbedder, but embeds the assets of
+// m, keyed by asset key.
+func TestEmbedderAssets(t *testing.T, ae goembed.AssetEmbedder, m map[string]string) {
+	p, err := buildProgram(ae, AssetsFromMap(m))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.remove()
+	checkProgram(t, p, m)
+}
+
+// TestEmbedderCall is like TestEmbedder, but the loading function is
+// called with args, a list of Go expressions.  If wantErr is not
+// empty, it checks that loading the assets fails with an error
+// containing wantErr instead.
+func TestEmbedderCall(t *testing.T, ae goembed.AssetEmbedder, args, wantErr string) {
+	src := strings.Replace(mainProgram, "loadAssets()", "loadAssets("+args+")", -1)
+	p, err := buildMain(ae, GetTestAssets(), src, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.remove()
+	if wantErr == "" {
+		checkProgram(t, p, testAssets)
+		return
+	}
+	if _, err := p.run(1); err == nil || !strings.Contains(err.Error(), wantErr) {
+		t.Errorf("got error %v, want %q", err, wantErr)
+	}
+}
+
+// checkProgram checks that the loading function of p returns exact
+// replicas of the assets of m.
+func checkProgram(t *testing.T, p *generatedProgram, m map[string]string) {
+	got, err := p.run(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := digests(m)
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("asset %q: got %q, want %q", k, got[k], v)
+		}
+	}
+	for k := range got {
+		if _, ok := want[k]; !ok {
+			t.Errorf("unexpected asset %q", k)
+		}
+	}
+}
+
+// TestEmbedderBytes is like TestEmbedder, but configures ae with o,
+// set so that the loading function returns byte slices.  It checks
+// that the decode function of ae was rewritten to return byte slices,
+// rather than wrapped to convert the strings it returns, and that
+// assets with identical contents do not share their byte slices.
+func TestEmbedderBytes(t *testing.T, ae goembed.ConfigurableEmbedder, o goembed.Options) {
+	o.Bytes = true
+	ae.SetOptions(o)
+	p, err := buildProgram(ae, GetTestAssets())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.remove()
+	src, err := ioutil.ReadFile(filepath.Join(p.dir, "assets.generated.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(src, []byte("map[string][]byte")) {
+		t.Error("loading function does not return byte slices")
+	}
+	if bytes.Contains(src, []byte("decodeString")) {
+		t.Error("decode function converts strings")
+	}
+	checkProgram(t, p, testAssets)
+
+	// Identical assets are decoded once, but each key must get a
+	// byte slice of its own.
+	m := make(map[string]string)
+	for k, v := range testAssets {
+		m[k] = v
+		m[k+".copy"] = v
+	}
+	p, err = buildProgram(ae, AssetsFromMap(m))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.remove()
+	checkProgram(t, p, m)
+	p, err = buildMain(ae, AssetsFromMap(m), mutateProgram, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.remove()
+	out, err := exec.Command(p.binary).CombinedOutput()
+	if err != nil {
+		t.Fatalf("%v: %s", err, out)
+	}
+	if len(out) > 0 {
+		t.Errorf("byte slices shared between keys:\n%s", out)
+	}
+}
+
+// TestEmbedderAsm is like TestEmbedder, but configures ae with o, and
+// with a Go assembly source file receiving the assets larger than
+// o.AsmThreshold bytes.  It checks that at least one of the test
+// assets was moved to the assembly source file.
+func TestEmbedderAsm(t *testing.T, ae goembed.ConfigurableEmbedder, o goembed.Options) {
+	p, err := buildMain(ae, GetTestAssets(), mainProgram, &o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.remove()
+	asm, err := ioutil.ReadFile(filepath.Join(p.dir, "assets.generated.s"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(asm, []byte("GLOBL")) {
+		t.Errorf("no asset in assembly source file:\n%s", asm)
+	}
+	checkProgram(t, p, testAssets)
+}
+
+// TestEmbedderLookup checks that the Go source file generated by ae
+// provides a lookup function, named after the loading function with
+// "Lookup" appended, that finds each of the test assets by key.
+func TestEmbedderLookup(t *testing.T, ae goembed.AssetEmbedder) {
+	p, err := buildMain(ae, GetTestAssets(), lookupProgram, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.remove()
+	out, err := exec.Command(p.binary).CombinedOutput()
+	if err != nil {
+		t.Fatalf("%v: %s", err, out)
+	}
+	if len(out) > 0 {
+		t.Error(string(out))
+	}
+}
+
+// TestEmbedderOpen checks that the Go source file generated by ae,
+// configured with o and an open function named openAsset, provides
+// streams reading each of the test assets, and seeking in them.
+func TestEmbedderOpen(t *testing.T, ae goembed.ConfigurableEmbedder, o goembed.Options) {
+	o.Open = "openAsset"
+	ae.SetOptions(o)
+	p, err := buildMain(ae, GetTestAssets(), openProgram, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.remove()
+	out, err := exec.Command(p.binary).CombinedOutput()
+	if err != nil {
+		t.Fatalf("%v: %s", err, out)
+	}
+	if len(out) > 0 {
+		t.Error(string(out))
+	}
+}
+
+// TestEmbedderDigests checks that the Go source file generated by ae,
+// configured with o and verification of the asset digests, records
+// the SHA-256 digest of each of the test assets and of the bundle, and
+// that the loading function rejects an asset whose digest does not
+// match, naming it.
+func TestEmbedderDigests(t *testing.T, ae goembed.ConfigurableEmbedder, o goembed.Options) {
+	o.Verify = true
+	ae.SetOptions(o)
+	p, err := buildMain(ae, GetTestAssets(), digestProgram, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.remove()
+	got, err := p.run(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := make([]string, 0, len(testAssets))
+	for k := range testAssets {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	want := make(map[string]string)
+	var list bytes.Buffer
+	for _, k := range keys {
+		want[k] = fmt.Sprintf("%x", sha256.Sum256([]byte(testAssets[k])))
+		fmt.Fprintf(&list, "%s  %s\n", want[k], k)
+	}
+	want[""] = fmt.Sprintf("%x", sha256.Sum256(list.Bytes()))
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("digest %q: got %q, want %q", k, got[k], v)
+		}
+	}
+	for k := range got {
+		if _, ok := want[k]; !ok {
+			t.Errorf("unexpected digest %q", k)
+		}
+	}
+
+	// Record a wrong digest for one of the assets.
+	name := filepath.Join(p.dir, "assets.generated.go")
+	src, err := ioutil.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := keys[0]
+	entry := fmt.Sprintf("{%q, %q}", key, want[key])
+	if !bytes.Contains(src, []byte(entry)) {
+		t.Fatalf("no digest entry %s in generated source", entry)
+	}
+	wrong := fmt.Sprintf("{%q, \"%x\"}", key, sha256.Sum256(nil))
+	src = bytes.Replace(src, []byte(entry), []byte(wrong), 1)
+	if err := ioutil.WriteFile(name, src, 0644); err != nil {
+		t.Fatal(err)
+	}
+	out, err := goCommand(p.dir, "build", "-o", p.binary).CombinedOutput()
+	if err != nil {
+		t.Fatalf("go build: %v\n%s", err, out)
+	}
+	_, err = p.run(1)
+	if msg := key + ": SHA-256 digest mismatch"; err == nil || !strings.Contains(err.Error(), msg) {
+		t.Errorf("loading tampered assets: got error %v, want %q", err, msg)
+	}
+}
+
+// TestEmbedderError checks that the Go source file generated by ae,
+// configured with o, once old is replaced with new in it, fails to
+// load the assets with an error containing wantErr, and that the
+// function named after the loading function with "must" prepended
+// panics with that error.
+func TestEmbedderError(t *testing.T, ae goembed.ConfigurableEmbedder, o goembed.Options, old, new, wantErr string) {
+	ae.SetOptions(o)
+	p, err := buildMain(ae, GetTestAssets(), errorProgram, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.remove()
+	name := filepath.Join(p.dir, "assets.generated.go")
+	src, err := ioutil.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(src, []byte(old)) {
+		t.Fatalf("%q not found in generated source", old)
+	}
+	src = bytes.Replace(src, []byte(old), []byte(new), -1)
+	if err := ioutil.WriteFile(name, src, 0644); err != nil {
+		t.Fatal(err)
+	}
+	out, err := goCommand(p.dir, "build", "-o", p.binary).CombinedOutput()
+	if err != nil {
+		t.Fatalf("go build: %v\n%s", err, out)
+	}
+	out, err = exec.Command(p.binary).CombinedOutput()
+	if err != nil {
+		t.Fatalf("%v: %s", err, out)
+	}
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("unexpected output:\n%s", out)
+	}
+	if !strings.Contains(lines[0], wantErr) {
+		t.Errorf("loading function: got error %q, want %q", lines[0], wantErr)
+	}
+	if lines[1] != lines[0] {
+		t.Errorf("panicking loading function: got %q, want %q", lines[1], lines[0])
+	}
+}
+
+// TestEmbedderConsts checks that the Go source file generated by ae,
+// configured with o and constants prefixed with "Asset", declares the
+// constants of consts, keyed by name, holding the keys of the assets
+// of m, and the sorted list of the keys, named assetNames.
+func TestEmbedderConsts(t *testing.T, ae goembed.ConfigurableEmbedder, o goembed.Options, m map[string]string, consts map[string]string) {
+	o.Consts = "Asset"
+	ae.SetOptions(o)
+	var src bytes.Buffer
+	err := template.Must(template.New("").Parse(constsProgram)).Execute(&src, map[string]interface{}{
+		"Consts": consts,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := buildMain(ae, AssetsFromMap(m), src.String(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.remove()
+	out, err := exec.Command(p.binary).CombinedOutput()
+	if err != nil {
+		t.Fatalf("%v: %s", err, out)
+	}
+	if len(out) > 0 {
+		t.Error(string(out))
+	}
+}
+
+// TestEmbedderConstsError checks that embedding the assets of m,
+// keyed by asset key, using ae configured with o, which sets Consts,
+// fails with an error containing each of wantErrs.
+func TestEmbedderConstsError(t *testing.T, ae goembed.ConfigurableEmbedder, o goembed.Options, m map[string]string, wantErrs ...string) {
+	ae.SetOptions(o)
+	_, err := ae.AssetEmbed(ioutil.Discard, AssetsFromMap(m), "main", "loadAssets")
+	if err == nil {
+		t.Fatal("no error")
+	}
+	for _, s := range wantErrs {
+		if !strings.Contains(err.Error(), s) {
+			t.Errorf("got error %v, want %q", err, s)
+		}
+	}
+}
+
+// A DecoderTest is a test case of TestDecoder: the decode function
+// must return Decoded when called with Encoded, or else fail with an
+// error containing Err, if not empty.
+type DecoderTest struct {
+	Encoded, Decoded, Err string
+}
+
+// decoderProgram calls the decode function with each of the encoded
+// strings of the test cases, and prints the returned string or error
+// of each of them.
+const decoderProgram = `package main
+
+import ({{range .Imports}}
+	{{printf "%q" .}}{{end}}
+	"fmt"
+)
+
+func main() {
+	decode := {{.DecodeFunc}}
+	for _, s := range []string{ {{- range .Tests}}
+		{{printf "%q" .Encoded}},{{end}}
+	} {
+		a, err := decode(s)
+		if err != nil {
+			fmt.Printf("error\t%q\n", err.Error())
+		} else {
+			fmt.Printf("ok\t%q\n", a)
+		}
+	}
+}
+`
+
+// TestDecoder checks that the decode function decodeFunc of an
+// embedder, whose imports are listed in imports, decodes the encoded
+// strings of tests, or rejects them.
+func TestDecoder(t *testing.T, decodeFunc string, imports []string, tests []DecoderTest) {
+	var src bytes.Buffer
+	err := template.Must(template.New("").Parse(decoderProgram)).Execute(&src, map[string]interface{}{
+		"Imports":    imports,
+		"DecodeFunc": decodeFunc,
+		"Tests":      tests,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir(os.TempDir(), "embedtesting")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string][]byte{
+		"go.mod":  []byte("module decoder\n"),
+		"main.go": src.Bytes(),
+	}
+	for name, contents := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), contents, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	out, err := goCommand(dir, "run", ".").CombinedOutput()
+	if err != nil {
+		t.Fatalf("go run: %v\n%s", err, out)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(out), "\n"), "\n")
+	if len(lines) != len(tests) {
+		t.Fatalf("got %d results, want %d:\n%s", len(lines), len(tests), out)
+	}
+	for i, tt := range tests {
+		fields := strings.SplitN(lines[i], "\t", 2)
+		got, err := strconv.Unquote(fields[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch {
+		case tt.Err == "" && (fields[0] != "ok" || got != tt.Decoded):
+			t.Errorf("test %d: got %s %.100q, want %.100q", i, fields[0], got, tt.Decoded)
+		case tt.Err != "" && (fields[0] != "error" || !strings.Contains(got, tt.Err)):
+			t.Errorf("test %d: got %s %.100q, want error containing %q", i, fields[0], got, tt.Err)
+		}
+	}
+}
+
+// ZlibDecoderTests returns test cases of TestDecoder for the decode
+// functions of the embedders that compress assets using package
+// zlibcompress, which encode the compressed data using encode.  The
+// test cases include data that decompresses to more or fewer bytes
+// than recorded, such as decompression bombs, truncated data, and
+// data followed by trailing bytes.
+func ZlibDecoderTests(encode func([]byte) string) []DecoderTest {
+	// The formats of package zlibcompress, whose tests import
+	// this package.
+	const stored, chunked, compressed = 0, 1, 2
+	deflate := func(s string) []byte {
+		var b bytes.Buffer
+		w := zlib.NewWriter(&b)
+		w.Write([]byte(s))
+		w.Close()
+		return b.Bytes()
+	}
+	header := func(format byte, n ...int) []byte {
+		b := []byte{format}
+		for _, v := range n {
+			b = binary.BigEndian.AppendUint32(b, uint32(v))
+		}
+		return b
+	}
+	join := func(b ...[]byte) string {
+		return encode(bytes.Join(b, nil))
+	}
+	hello := deflate("hello world")
+	bomb := deflate(strings.Repeat("\x00", 1<<20))
+	abcd, efg := deflate("abcd"), deflate("efg")
+	return []DecoderTest{
+		{Encoded: join(header(stored), []byte("raw")), Decoded: "raw"},
+		{Encoded: join(header(compressed, 11), hello), Decoded: "hello world"},
+		{Encoded: join(header(compressed, 11), bomb), Err: "longer than recorded"},
+		{Encoded: join(header(compressed, 100), hello), Err: "shorter than recorded"},
+		{Encoded: join(header(compressed, 11), hello, []byte("xx")), Err: "trailing data"},
+		{Encoded: join(header(compressed, 11), hello[:len(hello)-3]), Err: "unexpected EOF"},
+		{Encoded: join(header(compressed, 1)), Err: "EOF"},
+		{
+			Encoded: join(header(chunked, 7, 4, 2, 25, 25+len(abcd), 25+len(abcd)+len(efg)), abcd, efg),
+			Decoded: "abcdefg",
+		},
+		{
+			Encoded: join(header(chunked, 7, 4, 2, 25, 25+len(abcd), 25+len(abcd)+len(bomb)), abcd, bomb),
+			Err:     "longer than recorded",
+		},
+		{
+			Encoded: join(header(chunked, 7, 4, 2, 25, 25+len(efg), 25+len(efg)+len(abcd)), efg, abcd),
+			Err:     "shorter than recorded",
+		},
+		{
+			Encoded: join(header(chunked, 7, 4, 2, 25, 25+len(abcd), 25+len(abcd)+len(efg)), abcd, efg, []byte("xx")),
+			Err:     "trailing data",
+		},
+		{
+			Encoded: join(header(chunked, 7, 4, 2, 27, 27+len(abcd), 27+len(abcd)+len(efg)), []byte("xx"), abcd, efg),
+			Err:     "invalid chunk header",
+		},
+		{Encoded: join(header(chunked, 1<<30, 1<<30, 1, 21, 21+len(bomb)), bomb), Err: "invalid chunk header"},
+		{Encoded: join(header(chunked, 1<<30, 4, 2)), Err: "invalid chunk header"},
+		{Encoded: join(header(chunked, 7, 4, 2, 25, 25+len(abcd), 1<<20), abcd, efg), Err: "invalid chunk header"},
+		{Encoded: join(header(9)), Err: "unknown compression format"},
+		{Encoded: join(), Err: "missing header"},
+	}
+}
+
+// BenchmarkDecoder measures the throughput of the loading function of
+// the Go source file generated by ae, when decoding the test assets.
+// The loading function is run by a separate program, so the timings
+// include its startup time, which is negligible for large values of
+// b.N.
+func BenchmarkDecoder(b *testing.B, ae goembed.AssetEmbedder) {
+	b.StopTimer()
+	p, err := buildProgram(ae, GetTestAssets())
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer p.remove()
+	var size int64
+	for _, v := range testAssets {
+		size += int64(len(v))
+	}
+	b.SetBytes(size)
+	b.StartTimer()
+	_, err = p.run(b.N)
+	if err != nil {
+		b.Fatal(err)
+	}
+}
+
+// scalingSizes lists the numbers of assets embedded by BenchmarkBuild.
+var scalingSizes = [...]int{1000, 10000, 100000}
+
+// scalingAssets returns n small, distinct assets, spread over
+// directories of a thousand files.  The contents of the assets depend
+// on run, so that each run compiles a different program, rather than
+// hitting the build cache.
+func scalingAssets(n, run int) []*goembed.Asset {
+	m := make(map[string]string, n)
+	for i := 0; i < n; i++ {
+		m[fmt.Sprintf("/%03d/%06d.txt", i/1000, i)] = fmt.Sprintf("asset %d of run %d\n", i, run)
+	}
+	return AssetsFromMap(m)
+}
+
+// BenchmarkBuild measures the time needed to generate the Go source
+// file embedding 1k, 10k and 100k assets using ae, and to build it
+// into a program.  It also reports the size of the generated file.
+// Sizes above max are skipped, since the Go compiler may take hours
+// to build some of the generated files.
+func BenchmarkBuild(b *testing.B, ae goembed.AssetEmbedder, max int) {
+	for _, n := range scalingSizes {
+		if n > max {
+			continue
+		}
+		b.Run(fmt.Sprintf("%d", n), func(b *testing.B) {
+			var size int64
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				assets := scalingAssets(n, i)
+				b.StartTimer()
+				p, err := buildProgram(ae, assets)
+				if err != nil {
+					b.Fatal(err)
+				}
+				b.StopTimer()
+				fi, err := os.Stat(filepath.Join(p.dir, "assets.generated.go"))
+				if err != nil {
+					b.Fatal(err)
+				}
+				size = fi.Size()
+				p.remove()
+				b.StartTimer()
+			}
+			b.ReportMetric(float64(size), "src-bytes")
+		})
+	}
+}
+
+// lookupSizes lists the numbers of assets embedded by BenchmarkLookup.
+var lookupSizes = [...]int{10, 1000, 50000}
+
+// BenchmarkLookup measures the time needed to look up assets in the Go
+// source file generated by ae, embedding 10, 1k and 50k assets, using
+// the map returned by the loading function, and using the lookup
+// function.  The lookups are run by a separate program, which reports
+// the time spent building the map ("setup-ns"), and the time per
+// lookup ("ns/lookup").
+func BenchmarkLookup(b *testing.B, ae goembed.AssetEmbedder) {
+	for _, n := range lookupSizes {
+		p, err := buildMain(ae, scalingAssets(n, 0), lookupBenchProgram, nil)
+		if err != nil {
+			b.Fatal(err)
+		}
+		for _, mode := range []string{"map", "lookup"} {
+			b.Run(fmt.Sprintf("%d/%s", n, mode), func(b *testing.B) {
+				out, err := exec.Command(p.binary, mode, strconv.Itoa(b.N)).CombinedOutput()
+				if err != nil {
+					b.Fatalf("%v: %s", err, out)
+				}
+				var setup, lookup, size int64
+				if _, err := fmt.Sscan(string(out), &setup, &lookup, &size); err != nil {
+					b.Fatal(err)
+				}
+				b.ReportMetric(float64(setup), "setup-ns")
+				b.ReportMetric(float64(lookup)/float64(b.N), "ns/lookup")
+			})
+		}
+		p.remove()
+	}
+}
@@ -0,0 +1,93 @@
+// Package gzbase64embedder implements an asset embedder that
+// compresses assets using gzip, then encodes the resulting data as
+// base64 strings.
+//
+// In addition to the loading function, the generated Go source file
+// provides a function, named after the loading function with "Gzip"
+// appended, that returns the assets as RFC 1952 gzip streams, without
+// decompressing them:
+//
+//	func loadAssetsGzip() (map[string]string, error)
+//
+// An HTTP handler can thus serve the compressed data verbatim to the
+// clients that accept it, and only serve the decompressed data to the
+// others:
+//
+//	if strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+//		w.Header().Set("Content-Encoding", "gzip")
+//		io.WriteString(w, gzipAssets[r.URL.Path])
+//	} else {
+//		io.WriteString(w, assets[r.URL.Path])
+//	}
+package gzbase64embedder
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"io"
+
+	"github.com/jeanfric/goembed"
+)
+
+var (
+	imports = [...]string{"compress/gzip", "encoding/base64", "io/ioutil", "strings"}
+)
+
+const (
+	// rawSuffix is appended to the name of the loading function
+	// to name the function returning the gzip streams.
+	rawSuffix = "Gzip"
+
+	rawDecode = `func(s string) (string, error) {
+		b, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return "", err
+		}
+		return string(b), nil
+	}`
+
+	decode = `func(s string) (string, error) {
+		r, err := gzip.NewReader(strings.NewReader(s))
+		if err != nil {
+			return "", err
+		}
+		defer r.Close()
+		ob, err := ioutil.ReadAll(r)
+		if err != nil {
+			return "", err
+		}
+		return string(ob), nil
+	}`
+)
+
+func encode(contents io.Reader) (string, error) {
+	var zb bytes.Buffer
+	w := gzip.NewWriter(&zb)
+	_, err := io.Copy(w, contents)
+	if err != nil {
+		return "", err
+	}
+	err = w.Close()
+	if err != nil {
+		return "", err
+	}
+
+	return "`" + base64.StdEncoding.EncodeToString(zb.Bytes()) + "`", nil
+}
+
+// NewSequential creates a new sequential gzbase64embedder asset embedder.
+func NewSequential() goembed.AssetEmbedder {
+	e := goembed.NewSequentialEmbedder(encode, decode, imports[:])
+	e.SetName("gzbase64")
+	e.SetRawAccessor(rawSuffix, rawDecode)
+	return e
+}
+
+// NewConcurrent creates a new concurrent gzbase64embedder asset embedder.
+func NewConcurrent() goembed.AssetEmbedder {
+	e := goembed.NewConcurrentEmbedder(encode, decode, imports[:])
+	e.SetName("gzbase64")
+	e.SetRawAccessor(rawSuffix, rawDecode)
+	return e
+}
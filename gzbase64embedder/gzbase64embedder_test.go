@@ -0,0 +1,48 @@
+package gzbase64embedder
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/jeanfric/goembed"
+	"github.com/jeanfric/goembed/embedtesting"
+)
+
+func BenchmarkSequentialEmbedder(b *testing.B) {
+	embedtesting.BenchmarkEmbedder(b, NewSequential())
+}
+
+func BenchmarkConcurrentEmbedder(b *testing.B) {
+	embedtesting.BenchmarkEmbedder(b, NewConcurrent())
+}
+
+func TestBytesEmbedder(t *testing.T) {
+	embedtesting.TestEmbedderBytes(t, NewSequential().(goembed.ConfigurableEmbedder), goembed.Options{})
+}
+
+func TestOpenEmbedder(t *testing.T) {
+	embedtesting.TestEmbedderOpen(t, NewSequential().(goembed.ConfigurableEmbedder), goembed.Options{})
+}
+
+func TestParallelEmbedder(t *testing.T) {
+	embedtesting.TestEmbedderBytes(t, NewSequential().(goembed.ConfigurableEmbedder), goembed.Options{Parallel: true})
+	embedtesting.TestEmbedderOpen(t, NewConcurrent().(goembed.ConfigurableEmbedder), goembed.Options{Parallel: true})
+}
+
+func TestDigestsEmbedder(t *testing.T) {
+	embedtesting.TestEmbedderDigests(t, NewSequential().(goembed.ConfigurableEmbedder), goembed.Options{})
+	embedtesting.TestEmbedderDigests(t, NewConcurrent().(goembed.ConfigurableEmbedder), goembed.Options{Parallel: true})
+}
+
+func TestDecodeError(t *testing.T) {
+	r, err := encode(strings.NewReader(embedtesting.GetTestAsset("/README")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	notGzip := "`" + base64.StdEncoding.EncodeToString([]byte("this is not gzip data")) + "`"
+	for _, o := range []goembed.Options{{}, {Parallel: true}} {
+		embedtesting.TestEmbedderError(t, NewSequential().(goembed.ConfigurableEmbedder), o, r, "`!!!!`", "/README: gzbase64: illegal base64 data")
+		embedtesting.TestEmbedderError(t, NewSequential().(goembed.ConfigurableEmbedder), o, r, notGzip, "/README: gzbase64: gzip: invalid header")
+	}
+}
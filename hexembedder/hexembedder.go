@@ -11,7 +11,8 @@ import (
 )
 
 var (
-	imports = [...]string{"encoding/hex"}
+	imports       = [...]string{"encoding/hex"}
+	streamImports = [...]string{"encoding/hex", "io", "strings"}
 )
 
 const (
@@ -22,6 +23,11 @@ const (
 		}
 		return string(b), nil
 	}`
+
+	// Each byte is encoded as two hexadecimal digits.
+	stream = `func(s string, off int64) (io.Reader, error) {
+		return hex.NewDecoder(strings.NewReader(s[2*off:])), nil
+	}`
 )
 
 func encode(contents io.Reader) (string, error) {
@@ -34,10 +40,16 @@ func encode(contents io.Reader) (string, error) {
 
 // NewSequential creates a new sequential hexembedder asset embedder.
 func NewSequential() goembed.AssetEmbedder {
-	return goembed.NewSequentialEmbedder(encode, decode, imports[:])
+	e := goembed.NewSequentialEmbedder(encode, decode, imports[:])
+	e.SetName("hex")
+	e.SetStream(stream, streamImports[:]...)
+	return e
 }
 
 // NewConcurrent creates a new concurrent hexembedder asset embedder.
 func NewConcurrent() goembed.AssetEmbedder {
-	return goembed.NewConcurrentEmbedder(encode, decode, imports[:])
+	e := goembed.NewConcurrentEmbedder(encode, decode, imports[:])
+	e.SetName("hex")
+	e.SetStream(stream, streamImports[:]...)
+	return e
 }
@@ -3,6 +3,7 @@ package hexembedder
 import (
 	"testing"
 
+	"github.com/jeanfric/goembed"
 	"github.com/jeanfric/goembed/embedtesting"
 )
 
@@ -13,3 +14,11 @@ func BenchmarkSequentialEmbedder(b *testing.B) {
 func BenchmarkConcurrentEmbedder(b *testing.B) {
 	embedtesting.BenchmarkEmbedder(b, NewConcurrent())
 }
+
+func TestBytesEmbedder(t *testing.T) {
+	embedtesting.TestEmbedderBytes(t, NewSequential().(goembed.ConfigurableEmbedder), goembed.Options{})
+}
+
+func TestOpenEmbedder(t *testing.T) {
+	embedtesting.TestEmbedderOpen(t, NewSequential().(goembed.ConfigurableEmbedder), goembed.Options{})
+}
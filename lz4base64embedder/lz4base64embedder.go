@@ -0,0 +1,207 @@
+// Package lz4base64embedder implements an asset embedder that
+// compresses assets using the LZ4 block format, then encodes the
+// resulting data as base64 strings.
+//
+// LZ4 does not compress as well as zlib, but decompresses several
+// times faster, which makes it a good fit for assets that are loaded
+// every time a program starts.  The standard Go library provides no
+// LZ4 implementation, so the generated code includes its own compact
+// decoder.
+//
+// Each encoded asset consists of the length of the original data,
+// as an unsigned varint, followed by a single LZ4 block.
+package lz4base64embedder
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+
+	"github.com/jeanfric/goembed"
+)
+
+var (
+	imports = [...]string{"encoding/base64", "encoding/binary", "errors"}
+)
+
+const (
+	decode = `func(s string) (string, error) {
+		errCorrupt := errors.New("lz4: corrupt input")
+		b, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return "", err
+		}
+		n, i := binary.Uvarint(b)
+		if i <= 0 || n > 255*uint64(len(b)) {
+			return "", errCorrupt
+		}
+		dst := make([]byte, 0, n)
+		for i < len(b) {
+			token := int(b[i])
+			i++
+			l := token >> 4
+			if l == 15 {
+				for i < len(b) {
+					l += int(b[i])
+					i++
+					if b[i-1] != 255 {
+						break
+					}
+				}
+			}
+			if l > len(b)-i || l > cap(dst)-len(dst) {
+				return "", errCorrupt
+			}
+			dst = append(dst, b[i:i+l]...)
+			i += l
+			if i == len(b) {
+				break
+			}
+			if i+2 > len(b) {
+				return "", errCorrupt
+			}
+			offset := int(b[i]) | int(b[i+1])<<8
+			i += 2
+			l = token & 15
+			if l == 15 {
+				for i < len(b) {
+					l += int(b[i])
+					i++
+					if b[i-1] != 255 {
+						break
+					}
+				}
+			}
+			l += 4
+			if offset == 0 || offset > len(dst) || l > cap(dst)-len(dst) {
+				return "", errCorrupt
+			}
+			// The match may overlap with the bytes it produces,
+			// so copy it in chunks that double in size.
+			for start := len(dst) - offset; l > 0; {
+				c := len(dst) - start
+				if c > l {
+					c = l
+				}
+				dst = append(dst, dst[start:start+c]...)
+				l -= c
+			}
+		}
+		if uint64(len(dst)) != n {
+			return "", errCorrupt
+		}
+		return string(dst), nil
+	}`
+)
+
+const (
+	minMatch     = 4
+	maxOffset    = 65535
+	hashLog      = 16
+	lastLiterals = 5  // The last bytes of a block are always literals
+	matchLimit   = 12 // The last match starts at least this far from the end
+)
+
+// compressBlock compresses src into a single LZ4 block, using a greedy
+// parse driven by a hash table of the 4-byte sequences seen so far.
+func compressBlock(src []byte) []byte {
+	dst := make([]byte, 0, len(src)/2+16)
+	anchor := 0
+	if len(src) > matchLimit {
+		var table [1 << hashLog]int32 // Positions plus one
+		hash := func(i int) uint32 {
+			return binary.LittleEndian.Uint32(src[i:]) * 2654435761 >> (32 - hashLog)
+		}
+		for i := 0; i < len(src)-matchLimit; {
+			h := hash(i)
+			ref := int(table[h]) - 1
+			table[h] = int32(i + 1)
+			if ref < 0 || i-ref > maxOffset || binary.LittleEndian.Uint32(src[ref:]) != binary.LittleEndian.Uint32(src[i:]) {
+				// Skip faster through incompressible data.
+				i += 1 + (i-anchor)>>6
+				continue
+			}
+			for i > anchor && ref > 0 && src[i-1] == src[ref-1] {
+				i--
+				ref--
+			}
+			l := minMatch
+			for i+l < len(src)-lastLiterals && src[i+l] == src[ref+l] {
+				l++
+			}
+			dst = appendSequence(dst, src[anchor:i], i-ref, l)
+			i += l
+			anchor = i
+		}
+	}
+	return appendSequence(dst, src[anchor:], 0, 0)
+}
+
+// appendSequence appends to dst an LZ4 sequence made of literals
+// followed by a match of length matchLen at the given offset.  The
+// last sequence of a block has no match, and a matchLen of zero.
+func appendSequence(dst, literals []byte, offset, matchLen int) []byte {
+	token := 0
+	l := len(literals)
+	if l >= 15 {
+		token = 15 << 4
+	} else {
+		token = l << 4
+	}
+	m := matchLen - minMatch
+	if matchLen > 0 {
+		if m >= 15 {
+			token |= 15
+		} else {
+			token |= m
+		}
+	}
+	dst = append(dst, byte(token))
+	if l >= 15 {
+		dst = appendLength(dst, l-15)
+	}
+	dst = append(dst, literals...)
+	if matchLen > 0 {
+		dst = append(dst, byte(offset), byte(offset>>8))
+		if m >= 15 {
+			dst = appendLength(dst, m-15)
+		}
+	}
+	return dst
+}
+
+// appendLength appends the extra bytes of a literal or match length
+// to dst.
+func appendLength(dst []byte, n int) []byte {
+	for ; n >= 255; n -= 255 {
+		dst = append(dst, 255)
+	}
+	return append(dst, byte(n))
+}
+
+func encode(contents io.Reader) (string, error) {
+	b, err := ioutil.ReadAll(contents)
+	if err != nil {
+		return "", err
+	}
+	var size [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(size[:], uint64(len(b)))
+	zb := append(size[:n], compressBlock(b)...)
+
+	return "`" + base64.StdEncoding.EncodeToString(zb) + "`", nil
+}
+
+// NewSequential creates a new sequential lz4base64embedder asset embedder.
+func NewSequential() goembed.AssetEmbedder {
+	e := goembed.NewSequentialEmbedder(encode, decode, imports[:])
+	e.SetName("lz4base64")
+	return e
+}
+
+// NewConcurrent creates a new concurrent lz4base64embedder asset embedder.
+func NewConcurrent() goembed.AssetEmbedder {
+	e := goembed.NewConcurrentEmbedder(encode, decode, imports[:])
+	e.SetName("lz4base64")
+	return e
+}
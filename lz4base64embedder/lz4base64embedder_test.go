@@ -0,0 +1,47 @@
+package lz4base64embedder
+
+import (
+	"bytes"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/jeanfric/goembed"
+	"github.com/jeanfric/goembed/embedtesting"
+)
+
+func TestEmbedder(t *testing.T) {
+	random := make([]byte, 100000)
+	rand.New(rand.NewSource(1)).Read(random)
+	m := map[string]string{
+		"/empty":   "",
+		"/short":   "hello",
+		"/limit":   "aaaaaaaaaaaaa",
+		"/run":     strings.Repeat("a", 100000),
+		"/pattern": strings.Repeat("abcdefg", 10000),
+		"/random":  string(random),
+		"/mixed":   string(bytes.Repeat(random[:300], 50)),
+	}
+	embedtesting.TestEmbedderAssets(t, NewSequential(), m)
+	embedtesting.TestEmbedder(t, NewConcurrent())
+}
+
+func BenchmarkSequentialEmbedder(b *testing.B) {
+	embedtesting.BenchmarkEmbedder(b, NewSequential())
+}
+
+func BenchmarkConcurrentEmbedder(b *testing.B) {
+	embedtesting.BenchmarkEmbedder(b, NewConcurrent())
+}
+
+func BenchmarkDecoder(b *testing.B) {
+	embedtesting.BenchmarkDecoder(b, NewSequential())
+}
+
+func TestBytesEmbedder(t *testing.T) {
+	embedtesting.TestEmbedderBytes(t, NewSequential().(goembed.ConfigurableEmbedder), goembed.Options{})
+}
+
+func TestOpenEmbedder(t *testing.T) {
+	embedtesting.TestEmbedderOpen(t, NewSequential().(goembed.ConfigurableEmbedder), goembed.Options{})
+}
@@ -0,0 +1,403 @@
+package lzmabase64embedder
+
+import (
+	"math/bits"
+)
+
+// The encoder produces a raw LZMA stream, with lc=3, lp=0 and pb=2,
+// and without an end marker: the decoder stops once it has produced
+// the number of bytes stored before the stream.  The probability
+// models are laid out exactly as in the decoder emitted in the
+// generated code (see the decode constant).
+
+const (
+	numStates    = 12
+	posStates    = 4 // 1 << pb
+	endPosModel  = 14
+	minMatchLen  = 2
+	maxMatchLen  = 273
+	hashBits     = 16
+	maxChain     = 256 // Maximum number of candidates examined per match search
+	niceMatchLen = 128 // Matches at least this long are not improved upon
+)
+
+// A rangeEncoder implements the binary range coder of LZMA.
+type rangeEncoder struct {
+	low       uint64
+	rng       uint32
+	cache     byte
+	cacheSize int
+	out       []byte
+}
+
+func newRangeEncoder() *rangeEncoder {
+	return &rangeEncoder{rng: 0xffffffff, cacheSize: 1}
+}
+
+func (e *rangeEncoder) shiftLow() {
+	if uint32(e.low) < 0xff000000 || e.low>>32 != 0 {
+		carry := byte(e.low >> 32)
+		b := e.cache
+		for ; e.cacheSize > 0; e.cacheSize-- {
+			e.out = append(e.out, b+carry)
+			b = 0xff
+		}
+		e.cache = byte(e.low >> 24)
+	}
+	e.cacheSize++
+	e.low = uint64(uint32(e.low) << 8)
+}
+
+// bit encodes b using, and updating, the probability p.
+func (e *rangeEncoder) bit(p *uint16, b uint32) {
+	bound := (e.rng >> 11) * uint32(*p)
+	if b == 0 {
+		e.rng = bound
+		*p += (2048 - *p) >> 5
+	} else {
+		e.low += uint64(bound)
+		e.rng -= bound
+		*p -= *p >> 5
+	}
+	for e.rng < 1<<24 {
+		e.rng <<= 8
+		e.shiftLow()
+	}
+}
+
+// direct encodes the n lower bits of v, most significant first, with
+// fixed probabilities.
+func (e *rangeEncoder) direct(v uint32, n uint) {
+	for ; n > 0; n-- {
+		e.rng >>= 1
+		if v>>(n-1)&1 != 0 {
+			e.low += uint64(e.rng)
+		}
+		for e.rng < 1<<24 {
+			e.rng <<= 8
+			e.shiftLow()
+		}
+	}
+}
+
+// tree encodes the n lower bits of v, most significant first, using
+// the binary tree of probabilities p.
+func (e *rangeEncoder) tree(p []uint16, n uint, v uint32) {
+	m := uint32(1)
+	for ; n > 0; n-- {
+		b := v >> (n - 1) & 1
+		e.bit(&p[m], b)
+		m = m<<1 | b
+	}
+}
+
+// reverse encodes the n lower bits of v, least significant first,
+// using the binary tree of probabilities p.
+func (e *rangeEncoder) reverse(p []uint16, n uint, v uint32) {
+	m := uint32(1)
+	for ; n > 0; n-- {
+		b := v & 1
+		v >>= 1
+		e.bit(&p[m], b)
+		m = m<<1 | b
+	}
+}
+
+func (e *rangeEncoder) flush() []byte {
+	for i := 0; i < 5; i++ {
+		e.shiftLow()
+	}
+	return e.out
+}
+
+func newProbs(n int) []uint16 {
+	p := make([]uint16, n)
+	for i := range p {
+		p[i] = 1024
+	}
+	return p
+}
+
+// A lengthEncoder encodes match lengths, minus minMatchLen.  Its
+// probabilities are: choice, choice2, 8 low and 8 mid trees per
+// position state, and the high tree.
+type lengthEncoder []uint16
+
+func newLengthEncoder() lengthEncoder {
+	return lengthEncoder(newProbs(2 + 2*posStates*8 + 256))
+}
+
+func (p lengthEncoder) encode(e *rangeEncoder, l, posState uint32) {
+	switch {
+	case l < 8:
+		e.bit(&p[0], 0)
+		e.tree(p[2+posState*8:], 3, l)
+	case l < 16:
+		e.bit(&p[0], 1)
+		e.bit(&p[1], 0)
+		e.tree(p[34+posState*8:], 3, l-8)
+	default:
+		e.bit(&p[0], 1)
+		e.bit(&p[1], 1)
+		e.tree(p[66:], 8, l-16)
+	}
+}
+
+// An lzmaEncoder holds the state shared by the encoder and the
+// decoder: the probability models, the state machine and the last
+// four match distances.
+type lzmaEncoder struct {
+	rc         *rangeEncoder
+	isMatch    []uint16
+	isRep      []uint16
+	isRepG0    []uint16
+	isRepG1    []uint16
+	isRepG2    []uint16
+	isRep0Long []uint16
+	literal    []uint16
+	posSlot    []uint16
+	specPos    []uint16
+	align      []uint16
+	matchLen   lengthEncoder
+	repLen     lengthEncoder
+	state      uint32
+	reps       [4]uint32
+}
+
+func newLZMAEncoder() *lzmaEncoder {
+	return &lzmaEncoder{
+		rc:         newRangeEncoder(),
+		isMatch:    newProbs(numStates * posStates),
+		isRep:      newProbs(numStates),
+		isRepG0:    newProbs(numStates),
+		isRepG1:    newProbs(numStates),
+		isRepG2:    newProbs(numStates),
+		isRep0Long: newProbs(numStates * posStates),
+		literal:    newProbs(8 * 0x300),
+		posSlot:    newProbs(4 * 64),
+		specPos:    newProbs(115),
+		align:      newProbs(16),
+		matchLen:   newLengthEncoder(),
+		repLen:     newLengthEncoder(),
+	}
+}
+
+// encodeLiteral encodes the byte at position i of src.
+func (z *lzmaEncoder) encodeLiteral(src []byte, i int) {
+	posState := uint32(i) & (posStates - 1)
+	z.rc.bit(&z.isMatch[z.state*posStates+posState], 0)
+	prev := byte(0)
+	if i > 0 {
+		prev = src[i-1]
+	}
+	p := z.literal[uint32(prev>>5)*0x300:]
+	cur := uint32(src[i])
+	ctx := uint32(1)
+	// After a match, the byte following the match at distance
+	// rep0 predicts the literal, until the first bit that differs.
+	matched := z.state >= 7
+	match := uint32(0)
+	if matched {
+		match = uint32(src[i-int(z.reps[0])-1])
+	}
+	for n := uint(8); n > 0; n-- {
+		b := cur >> (n - 1) & 1
+		if matched {
+			mb := match >> (n - 1) & 1
+			z.rc.bit(&p[(1+mb)<<8+ctx], b)
+			matched = mb == b
+		} else {
+			z.rc.bit(&p[ctx], b)
+		}
+		ctx = ctx<<1 | b
+	}
+	switch {
+	case z.state < 4:
+		z.state = 0
+	case z.state < 10:
+		z.state -= 3
+	default:
+		z.state -= 6
+	}
+}
+
+// encodeMatch encodes a match of length l at distance dist+1.
+func (z *lzmaEncoder) encodeMatch(i int, dist, l uint32) {
+	posState := uint32(i) & (posStates - 1)
+	z.rc.bit(&z.isMatch[z.state*posStates+posState], 1)
+	z.rc.bit(&z.isRep[z.state], 0)
+	z.matchLen.encode(z.rc, l-minMatchLen, posState)
+
+	lenState := l - minMatchLen
+	if lenState > 3 {
+		lenState = 3
+	}
+	slot := dist
+	if dist >= 4 {
+		n := uint32(bits.Len32(dist))
+		slot = 2*(n-1) | dist>>(n-2)&1
+	}
+	z.rc.tree(z.posSlot[lenState*64:], 6, slot)
+	if slot >= 4 {
+		n := uint(slot>>1 - 1)
+		base := (2 | slot&1) << n
+		if slot < endPosModel {
+			z.rc.reverse(z.specPos[base-slot:], n, dist-base)
+		} else {
+			z.rc.direct((dist-base)>>4, n-4)
+			z.rc.reverse(z.align, 4, dist-base)
+		}
+	}
+
+	z.reps[3], z.reps[2], z.reps[1], z.reps[0] = z.reps[2], z.reps[1], z.reps[0], dist
+	if z.state < 7 {
+		z.state = 7
+	} else {
+		z.state = 10
+	}
+}
+
+// encodeRep encodes a match of length l at the distance of the r-th
+// most recent match.
+func (z *lzmaEncoder) encodeRep(i int, r int, l uint32) {
+	posState := uint32(i) & (posStates - 1)
+	z.rc.bit(&z.isMatch[z.state*posStates+posState], 1)
+	z.rc.bit(&z.isRep[z.state], 1)
+	if r == 0 {
+		z.rc.bit(&z.isRepG0[z.state], 0)
+		z.rc.bit(&z.isRep0Long[z.state*posStates+posState], 1)
+	} else {
+		z.rc.bit(&z.isRepG0[z.state], 1)
+		if r == 1 {
+			z.rc.bit(&z.isRepG1[z.state], 0)
+		} else {
+			z.rc.bit(&z.isRepG1[z.state], 1)
+			z.rc.bit(&z.isRepG2[z.state], uint32(r-2))
+		}
+		d := z.reps[r]
+		copy(z.reps[1:r+1], z.reps[:r])
+		z.reps[0] = d
+	}
+	z.repLen.encode(z.rc, l-minMatchLen, posState)
+	if z.state < 7 {
+		z.state = 8
+	} else {
+		z.state = 11
+	}
+}
+
+// A matchFinder finds the longest earlier occurrences of the data at
+// a given position, using hash chains over 3-byte sequences.
+type matchFinder struct {
+	src  []byte
+	head []int32
+	prev []int32
+	next int // The next position to insert
+}
+
+func newMatchFinder(src []byte) *matchFinder {
+	mf := &matchFinder{
+		src:  src,
+		head: make([]int32, 1<<hashBits),
+		prev: make([]int32, len(src)),
+	}
+	for i := range mf.head {
+		mf.head[i] = -1
+	}
+	return mf
+}
+
+func (mf *matchFinder) hash(i int) uint32 {
+	v := uint32(mf.src[i]) | uint32(mf.src[i+1])<<8 | uint32(mf.src[i+2])<<16
+	return v * 2654435761 >> (32 - hashBits)
+}
+
+// insert adds the positions before end to the hash chains.
+func (mf *matchFinder) insert(end int) {
+	for ; mf.next < end && mf.next+3 <= len(mf.src); mf.next++ {
+		h := mf.hash(mf.next)
+		mf.prev[mf.next] = mf.head[h]
+		mf.head[h] = int32(mf.next)
+	}
+	if mf.next < end {
+		mf.next = end
+	}
+}
+
+// matchLen returns the length of the match at position i, at the
+// given distance, up to maxMatchLen.
+func (mf *matchFinder) matchLen(i, dist int) int {
+	l := 0
+	for l < maxMatchLen && i+l < len(mf.src) && mf.src[i+l] == mf.src[i+l-dist] {
+		l++
+	}
+	return l
+}
+
+// find returns the length and distance of the longest match at
+// position i.
+func (mf *matchFinder) find(i int) (int, int) {
+	mf.insert(i)
+	bestLen, bestDist := 0, 0
+	if i+3 > len(mf.src) {
+		return 0, 0
+	}
+	cand := mf.head[mf.hash(i)]
+	for n := 0; cand >= 0 && n < maxChain; n++ {
+		dist := i - int(cand)
+		if l := mf.matchLen(i, dist); l > bestLen {
+			bestLen, bestDist = l, dist
+			if l >= niceMatchLen {
+				break
+			}
+		}
+		cand = mf.prev[cand]
+	}
+	return bestLen, bestDist
+}
+
+// compress compresses src into a raw LZMA stream, using greedy
+// parsing with one step of lazy evaluation, and favoring the reuse of
+// recent match distances.
+func compress(src []byte) []byte {
+	z := newLZMAEncoder()
+	mf := newMatchFinder(src)
+	for i := 0; i < len(src); {
+		mainLen, mainDist := mf.find(i)
+		// Distant short matches cost more than literals.
+		if mainLen == 3 && mainDist > 1<<14 {
+			mainLen = 0
+		}
+
+		repLen, rep := 0, 0
+		for r, d := range z.reps {
+			if int(d) < i {
+				if l := mf.matchLen(i, int(d)+1); l > repLen {
+					repLen, rep = l, r
+				}
+			}
+		}
+		if repLen >= minMatchLen && repLen+1 >= mainLen {
+			z.encodeRep(i, rep, uint32(repLen))
+			i += repLen
+			continue
+		}
+
+		if mainLen >= 3 {
+			if mainLen < niceMatchLen {
+				if nextLen, _ := mf.find(i + 1); nextLen > mainLen+1 {
+					z.encodeLiteral(src, i)
+					i++
+					continue
+				}
+			}
+			z.encodeMatch(i, uint32(mainDist-1), uint32(mainLen))
+			i += mainLen
+			continue
+		}
+
+		z.encodeLiteral(src, i)
+		i++
+	}
+	return z.rc.flush()
+}
@@ -0,0 +1,265 @@
+// Package lzmabase64embedder implements an asset embedder that
+// compresses assets using LZMA, then encodes the resulting data as
+// base64 strings.
+//
+// LZMA compresses better than zlib and bzip2, at the expense of
+// slower compression and decompression, which makes it a good fit
+// for large assets that are seldom loaded.  The standard Go library
+// provides no LZMA implementation, so the generated code includes its
+// own range decoder and LZ decoder.
+//
+// Each encoded asset consists of the length of the original data,
+// as an unsigned varint, followed by a raw LZMA stream using lc=3,
+// lp=0 and pb=2, without end marker.
+package lzmabase64embedder
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+
+	"github.com/jeanfric/goembed"
+)
+
+var (
+	imports = [...]string{"encoding/base64", "encoding/binary", "errors"}
+)
+
+const (
+	decode = `func(s string) (string, error) {
+		errCorrupt := errors.New("lzma: corrupt input")
+		in, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return "", err
+		}
+		// A range-coded bit costs at least 1/45 bit of input, so
+		// LZMA cannot expand its input more than about 7600 times.
+		size, i := binary.Uvarint(in)
+		if i <= 0 || size > 8192*uint64(len(in)) || len(in) < i+5 || in[i] != 0 {
+			return "", errCorrupt
+		}
+		rng, code := uint32(0xffffffff), binary.BigEndian.Uint32(in[i+1:])
+		in = in[i+5:]
+		truncated := false
+		normalize := func() {
+			if rng < 1<<24 {
+				rng <<= 8
+				code <<= 8
+				if len(in) == 0 {
+					truncated = true
+					return
+				}
+				code |= uint32(in[0])
+				in = in[1:]
+			}
+		}
+		bit := func(p *uint16) uint32 {
+			bound := (rng >> 11) * uint32(*p)
+			b := uint32(0)
+			if code < bound {
+				rng = bound
+				*p += (2048 - *p) >> 5
+			} else {
+				code -= bound
+				rng -= bound
+				*p -= *p >> 5
+				b = 1
+			}
+			normalize()
+			return b
+		}
+		tree := func(p []uint16, n uint) uint32 {
+			m := uint32(1)
+			for i := uint(0); i < n; i++ {
+				m = m<<1 | bit(&p[m])
+			}
+			return m - 1<<n
+		}
+		reverse := func(p []uint16, n uint) uint32 {
+			m, v := uint32(1), uint32(0)
+			for i := uint(0); i < n; i++ {
+				b := bit(&p[m])
+				m = m<<1 | b
+				v |= b << i
+			}
+			return v
+		}
+		probs := func(n int) []uint16 {
+			p := make([]uint16, n)
+			for i := range p {
+				p[i] = 1024
+			}
+			return p
+		}
+		length := func() func(uint32) uint32 {
+			p := probs(322)
+			return func(posState uint32) uint32 {
+				if bit(&p[0]) == 0 {
+					return 2 + tree(p[2+posState*8:], 3)
+				}
+				if bit(&p[1]) == 0 {
+					return 10 + tree(p[34+posState*8:], 3)
+				}
+				return 18 + tree(p[66:], 8)
+			}
+		}
+		isMatch, isRep0Long := probs(48), probs(48)
+		isRep, isRepG0, isRepG1, isRepG2 := probs(12), probs(12), probs(12), probs(12)
+		literal, posSlot, specPos, align := probs(0x1800), probs(256), probs(115), probs(16)
+		matchLen, repLen := length(), length()
+		var state, rep0, rep1, rep2, rep3 uint32
+
+		// Trust the recorded length only as far as usual
+		// compression ratios go, so that corrupt input cannot
+		// allocate much before being rejected.
+		capacity := size
+		if limit := 16 * uint64(len(in)); capacity > limit {
+			capacity = limit
+		}
+		out := make([]byte, 0, capacity)
+		for uint64(len(out)) < size && !truncated {
+			posState := uint32(len(out)) & 3
+			if bit(&isMatch[state*4+posState]) == 0 {
+				prev := byte(0)
+				if len(out) > 0 {
+					prev = out[len(out)-1]
+				}
+				p := literal[uint32(prev>>5)*0x300:]
+				sym := uint32(1)
+				if state >= 7 {
+					if uint64(rep0) >= uint64(len(out)) {
+						return "", errCorrupt
+					}
+					match := uint32(out[len(out)-int(rep0)-1])
+					for sym < 0x100 {
+						mb := match >> 7 & 1
+						match <<= 1
+						b := bit(&p[(1+mb)<<8+sym])
+						sym = sym<<1 | b
+						if mb != b {
+							break
+						}
+					}
+				}
+				for sym < 0x100 {
+					sym = sym<<1 | bit(&p[sym])
+				}
+				out = append(out, byte(sym))
+				if state < 4 {
+					state = 0
+				} else if state < 10 {
+					state -= 3
+				} else {
+					state -= 6
+				}
+				continue
+			}
+			var l uint32
+			if bit(&isRep[state]) == 1 {
+				if bit(&isRepG0[state]) == 0 {
+					if bit(&isRep0Long[state*4+posState]) == 0 {
+						l = 1
+					}
+				} else {
+					d := rep1
+					if bit(&isRepG1[state]) == 1 {
+						if bit(&isRepG2[state]) == 0 {
+							d = rep2
+						} else {
+							d, rep3 = rep3, rep2
+						}
+						rep2 = rep1
+					}
+					rep0, rep1 = d, rep0
+				}
+				if l == 0 {
+					l = repLen(posState)
+				}
+				if state >= 7 {
+					state = 11
+				} else if l == 1 {
+					state = 9
+				} else {
+					state = 8
+				}
+			} else {
+				rep3, rep2, rep1 = rep2, rep1, rep0
+				l = matchLen(posState)
+				lenState := l - 2
+				if lenState > 3 {
+					lenState = 3
+				}
+				slot := tree(posSlot[lenState*64:], 6)
+				rep0 = slot
+				if slot >= 4 {
+					n := uint(slot>>1 - 1)
+					rep0 = (2 | slot&1) << n
+					if slot < 14 {
+						rep0 += reverse(specPos[rep0-slot:], n)
+					} else {
+						v := uint32(0)
+						for i := n - 4; i > 0; i-- {
+							rng >>= 1
+							v <<= 1
+							if code >= rng {
+								code -= rng
+								v |= 1
+							}
+							normalize()
+						}
+						rep0 += v<<4 | reverse(align, 4)
+					}
+				}
+				if state < 7 {
+					state = 7
+				} else {
+					state = 10
+				}
+			}
+			if uint64(rep0) >= uint64(len(out)) || uint64(l) > size-uint64(len(out)) {
+				return "", errCorrupt
+			}
+			// The match may overlap with the bytes it produces,
+			// so copy it in chunks that double in size.
+			for start := len(out) - int(rep0) - 1; l > 0; {
+				c := uint32(len(out) - start)
+				if c > l {
+					c = l
+				}
+				out = append(out, out[start:start+int(c)]...)
+				l -= c
+			}
+		}
+		if truncated {
+			return "", errCorrupt
+		}
+		return string(out), nil
+	}`
+)
+
+func encode(contents io.Reader) (string, error) {
+	b, err := ioutil.ReadAll(contents)
+	if err != nil {
+		return "", err
+	}
+	var size [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(size[:], uint64(len(b)))
+	zb := append(size[:n], compress(b)...)
+
+	return "`" + base64.StdEncoding.EncodeToString(zb) + "`", nil
+}
+
+// NewSequential creates a new sequential lzmabase64embedder asset embedder.
+func NewSequential() goembed.AssetEmbedder {
+	e := goembed.NewSequentialEmbedder(encode, decode, imports[:])
+	e.SetName("lzmabase64")
+	return e
+}
+
+// NewConcurrent creates a new concurrent lzmabase64embedder asset embedder.
+func NewConcurrent() goembed.AssetEmbedder {
+	e := goembed.NewConcurrentEmbedder(encode, decode, imports[:])
+	e.SetName("lzmabase64")
+	return e
+}
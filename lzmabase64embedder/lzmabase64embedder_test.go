@@ -0,0 +1,92 @@
+package lzmabase64embedder
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/jeanfric/goembed"
+	"github.com/jeanfric/goembed/embedtesting"
+)
+
+func TestEmbedder(t *testing.T) {
+	random := make([]byte, 100000)
+	rand.New(rand.NewSource(1)).Read(random)
+	m := map[string]string{
+		"/empty":   "",
+		"/short":   "hello",
+		"/limit":   "aaaaaaaaaaaaa",
+		"/run":     strings.Repeat("a", 100000),
+		"/pattern": strings.Repeat("abcdefg", 10000),
+		"/random":  string(random),
+		"/mixed":   string(bytes.Repeat(random[:300], 50)),
+	}
+	embedtesting.TestEmbedderAssets(t, NewSequential(), m)
+	embedtesting.TestEmbedder(t, NewConcurrent())
+}
+
+func TestDecoder(t *testing.T) {
+	const corrupt = "lzma: corrupt input"
+	stream := func(size uint64, z []byte) []byte {
+		return append(binary.AppendUvarint(nil, size), z...)
+	}
+	encode := base64.StdEncoding.EncodeToString
+	hello := compress([]byte("hello world"))
+	random := make([]byte, 1000)
+	rand.New(rand.NewSource(1)).Read(random)
+	long := compress(random)
+
+	// A match going back further than the start of the data.
+	far := newLZMAEncoder()
+	far.encodeLiteral([]byte("a"), 0)
+	far.encodeMatch(1, 5, 2)
+	// A repeated match before any data.
+	rep := newLZMAEncoder()
+	rep.encodeRep(0, 0, 2)
+	// A match longer than the recorded length.
+	overlong := newLZMAEncoder()
+	overlong.encodeLiteral([]byte("a"), 0)
+	overlong.encodeMatch(1, 0, 10)
+	badFirst := stream(11, hello)
+	badFirst[1] = 1
+
+	embedtesting.TestDecoder(t, decode, imports[:], []embedtesting.DecoderTest{
+		{Encoded: encode(stream(11, hello)), Decoded: "hello world"},
+		{Encoded: encode(stream(0, compress(nil))), Decoded: ""},
+		{Encoded: "!!!!", Err: "illegal base64 data"},
+		{Encoded: "", Err: corrupt},
+		{Encoded: encode(stream(11, nil)), Err: corrupt},
+		{Encoded: encode(stream(11, hello[:4])), Err: corrupt},
+		{Encoded: encode(badFirst), Err: corrupt},
+		{Encoded: encode(stream(1<<40, hello)), Err: corrupt},
+		{Encoded: encode(stream(1<<62, hello)), Err: corrupt},
+		{Encoded: encode(stream(1000, long[:len(long)/2])), Err: corrupt},
+		{Encoded: encode(stream(1000, long[:8])), Err: corrupt},
+		{Encoded: encode(stream(3, far.rc.flush())), Err: corrupt},
+		{Encoded: encode(stream(2, rep.rc.flush())), Err: corrupt},
+		{Encoded: encode(stream(5, overlong.rc.flush())), Err: corrupt},
+	})
+}
+
+func BenchmarkSequentialEmbedder(b *testing.B) {
+	embedtesting.BenchmarkEmbedder(b, NewSequential())
+}
+
+func BenchmarkConcurrentEmbedder(b *testing.B) {
+	embedtesting.BenchmarkEmbedder(b, NewConcurrent())
+}
+
+func BenchmarkDecoder(b *testing.B) {
+	embedtesting.BenchmarkDecoder(b, NewSequential())
+}
+
+func TestBytesEmbedder(t *testing.T) {
+	embedtesting.TestEmbedderBytes(t, NewSequential().(goembed.ConfigurableEmbedder), goembed.Options{})
+}
+
+func TestOpenEmbedder(t *testing.T) {
+	embedtesting.TestEmbedderOpen(t, NewSequential().(goembed.ConfigurableEmbedder), goembed.Options{})
+}
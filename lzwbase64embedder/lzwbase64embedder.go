@@ -0,0 +1,66 @@
+// Package lzwbase64embedder implements an asset embedder that
+// compresses assets using LZW (with the most significant bit first
+// order and 8-bit literals, as in the TIFF and PDF file formats), then
+// encodes the resulting data as base64 strings.
+//
+// LZW usually does not compress as well as zlib, but decompresses
+// faster, which makes it a good fit for small text assets.
+package lzwbase64embedder
+
+import (
+	"bytes"
+	"compress/lzw"
+	"encoding/base64"
+	"io"
+
+	"github.com/jeanfric/goembed"
+)
+
+var (
+	imports = [...]string{"bytes", "compress/lzw", "encoding/base64", "io/ioutil"}
+)
+
+const (
+	decode = `func(s string) (string, error) {
+		b, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return "", err
+		}
+		r := lzw.NewReader(bytes.NewReader(b), lzw.MSB, 8)
+		defer r.Close()
+		ob, err := ioutil.ReadAll(r)
+		if err != nil {
+			return "", err
+		}
+		return string(ob), nil
+	}`
+)
+
+func encode(contents io.Reader) (string, error) {
+	var zb bytes.Buffer
+	w := lzw.NewWriter(&zb, lzw.MSB, 8)
+	_, err := io.Copy(w, contents)
+	if err != nil {
+		return "", err
+	}
+	err = w.Close()
+	if err != nil {
+		return "", err
+	}
+
+	return "`" + base64.StdEncoding.EncodeToString(zb.Bytes()) + "`", nil
+}
+
+// NewSequential creates a new sequential lzwbase64embedder asset embedder.
+func NewSequential() goembed.AssetEmbedder {
+	e := goembed.NewSequentialEmbedder(encode, decode, imports[:])
+	e.SetName("lzwbase64")
+	return e
+}
+
+// NewConcurrent creates a new concurrent lzwbase64embedder asset embedder.
+func NewConcurrent() goembed.AssetEmbedder {
+	e := goembed.NewConcurrentEmbedder(encode, decode, imports[:])
+	e.SetName("lzwbase64")
+	return e
+}
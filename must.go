@@ -0,0 +1,75 @@
+package goembed
+
+import (
+	"go/ast"
+	"go/parser"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// SetName sets the name of the embedder, such as "zbase64", which
+// the generated Go source file includes in the errors of the decode
+// function, together with the key of the asset being decoded.
+func (e *embedder) SetName(name string) {
+	e.name = name
+}
+
+// mustName returns the name of the function that panics rather than
+// returning the error of the loading function funcName: "must" is
+// prepended to funcName, and "Must" if funcName is exported.
+func mustName(funcName string) string {
+	r, n := utf8.DecodeRuneInString(funcName)
+	if unicode.IsUpper(r) {
+		return "Must" + funcName
+	}
+	return "must" + string(unicode.ToUpper(r)) + funcName[n:]
+}
+
+// paramArgs returns the arguments passing on the parameters params,
+// a Go parameter list such as "key []byte", to another function.
+func paramArgs(params string) string {
+	if params == "" {
+		return ""
+	}
+	x, err := parser.ParseExpr("func(" + params + ")")
+	if err != nil {
+		return ""
+	}
+	var args []string
+	for _, f := range x.(*ast.FuncType).Params.List {
+		for _, n := range f.Names {
+			if _, ok := f.Type.(*ast.Ellipsis); ok {
+				args = append(args, n.Name+"...")
+			} else {
+				args = append(args, n.Name)
+			}
+		}
+	}
+	return strings.Join(args, ", ")
+}
+
+// errorTemplate declares the function wrapping the errors of the
+// decode function with the key of the asset being decoded, and the
+// name of the embedder.
+const errorTemplate = `
+// {{.FuncName}}Error wraps err, returned when decoding the asset named
+// key.
+func {{.FuncName}}Error(key string, err error) error {
+	return fmt.Errorf("%s: {{if .Encoder}}%s: {{end}}%w", key, {{if .Encoder}}{{printf "%q" .Encoder}}, {{end}}err)
+}
+`
+
+// mustTemplate declares the function panicking rather than returning
+// the error of the loading function.
+const mustTemplate = `
+// {{.MustFunc}} is like {{.FuncName}}, but panics if the assets cannot
+// be loaded.
+func {{.MustFunc}}({{.Params}}) map[string]{{.ValueType}} {
+	assets, err := {{.FuncName}}({{.Args}})
+	if err != nil {
+		panic(err)
+	}
+	return assets
+}
+`
@@ -0,0 +1,163 @@
+package goembed
+
+import (
+	"io"
+)
+
+// Options control the layout of the Go source file generated by an
+// embedder.  The zero value of Options produces the default layout.
+type Options struct {
+	// Solid makes the embedder concatenate all the assets and
+	// encode them as a single piece of data, together with an
+	// index of the offset and length of each asset.  The loading
+	// function decodes the whole bundle at once, and returns
+	// substrings of it.
+	//
+	// Solid bundles take advantage of the redundancy across
+	// assets, at the expense of always decoding all of them.
+	Solid bool
+
+	// Blob makes the embedder concatenate all the assets in a
+	// single string constant, together with an index of the
+	// offset and length of each asset, sorted by key.  The
+	// loading function returns substrings of the constant,
+	// without decoding or copying them, and an additional
+	// function, named after the loading function with "Lookup"
+	// appended, looks up a single asset by key using a binary
+	// search:
+	//
+	//	func fnNameLookup(key string) (string, bool)
+	//
+	// Blob mode is only supported by embedders whose string
+	// representation of the assets is the assets themselves,
+	// such as quoted strings.
+	Blob bool
+
+	// Hash is like Blob, but the lookup function finds the index
+	// entry of an asset using a minimal perfect hash of the asset
+	// keys, computed when generating the Go source file, rather
+	// than using a binary search.
+	Hash bool
+
+	// Lookup names the lookup function generated in blob and
+	// hash modes.  If empty, the lookup function is named after
+	// the loading function with "Lookup" appended.
+	Lookup string
+
+	// Table makes the loading function decode the assets in a
+	// loop over a table of the encoded assets, rather than in a
+	// sequence of statements per asset.  The generated code is
+	// much smaller, so the Go compiler can cope with hundreds of
+	// thousands of assets.  Table has no effect in solid, blob and
+	// hash modes, nor with Open, whose loading functions are always
+	// table-driven.
+	Table bool
+
+	// Parallel makes the loading function decode the assets
+	// concurrently, with up to GOMAXPROCS assets decoded at the
+	// same time.  If the decoding of assets fails, the loading
+	// function returns the error of the first of them, as it would
+	// without Parallel.  Parallel implies Table, and has
+	// no effect in solid, blob and hash modes.
+	Parallel bool
+
+	// Bytes makes the loading function return the assets as byte
+	// slices rather than strings:
+	//
+	//	func fnName() (map[string][]byte, error)
+	//
+	// The decode functions of the embedders are rewritten to
+	// return the byte slices they decode, rather than copying
+	// them to strings.  Assets with identical contents are
+	// decoded once, but each key gets a byte slice of its own, so
+	// that writing to one asset leaves the others untouched.  Blob
+	// and hash modes do not support Bytes.
+	Bytes bool
+
+	// Asm, if not nil, receives a Go assembly source file, to be
+	// placed in the same package as the generated Go source file.
+	// The string representation of each asset whose encoded
+	// representation is larger than AsmThreshold bytes is stored
+	// in a read-only data symbol of that file, rather than in a
+	// string literal of the Go source file, so it bypasses the Go
+	// parser and type checker.  The loading function is the same.
+	// Blob and hash modes do not support Asm.
+	Asm          io.Writer
+	AsmThreshold int
+
+	// Open, if not empty, names an additional function of the
+	// generated Go source file that opens a single asset for
+	// reading, decoding it as it is read rather than as a whole:
+	//
+	//	func openAsset(name string) (io.ReadSeekCloser, error)
+	//
+	// It returns an error wrapping os.ErrNotExist if there is no
+	// asset named name.  Seeking is cheap for uncompressed
+	// encodings, and for assets compressed in chunks; other
+	// compressed assets are decompressed again from their
+	// beginning when seeking backward.  The returned stream also
+	// implements io.ReaderAt.  Solid, blob and hash
+	// modes, and Asm, do not support Open.
+	Open string
+
+	// Digests makes the generated Go source file record the
+	// SHA-256 digest of each asset, and of the whole bundle, as
+	// computed when generating it:
+	//
+	//	var fnNameDigests = [...]struct{ key, digest string }{...}
+	//	const fnNameBundleDigest = "..."
+	//	func fnNameDigest(key string) (string, bool)
+	//
+	// The digests are hexadecimal, so they can be logged or used
+	// as cache keys as is.  The bundle digest is the digest of the
+	// lines "digest  key" of the assets, sorted by key, as printed
+	// by the sha256sum command.
+	Digests bool
+
+	// Verify makes the loading function check the SHA-256 digest
+	// of each asset it decodes against the recorded one, and
+	// return an error naming the key of the first asset that does
+	// not match.  Verify implies Digests.  The streams returned by
+	// the open function are not verified.
+	Verify bool
+
+	// Consts, if not empty, makes the generated Go source file
+	// declare a string constant holding the key of each asset,
+	// named after the key, and prefixed with Consts, and a slice
+	// listing the keys, sorted, named after Consts with its first
+	// letter in lower case, followed by "Names".  With Consts set
+	// to "Asset":
+	//
+	//	const Asset_img_gopher__dpng = "/img/gopher.png"
+	//	var assetNames = []string{Asset_img_gopher__dpng, ...}
+	//
+	// The letters and digits of the key are kept as is, a slash
+	// followed by a letter or digit becomes "_", and any other
+	// character becomes "__" followed by a letter naming it, such
+	// as "d" for ".", "h" for "-" and "u" for "_", or "__x" followed
+	// by its hexadecimal code point and "_".  Distinct keys thus get
+	// distinct names, which only depend on the key.  The embedder
+	// fails if a name collides with another identifier of the
+	// generated Go source file.  Programs referring to assets using
+	// the constants fail to compile when the assets are renamed.
+	Consts string
+
+	// Log, if not nil, receives a line for each asset that is
+	// not embedded because its contents are identical to those
+	// of another asset, with the number of bytes saved.
+	Log io.Writer
+}
+
+// A ConfigurableEmbedder is an AssetEmbedder whose generated Go
+// source file can be adjusted using Options.  The embedders created
+// by NewSequentialEmbedder and NewConcurrentEmbedder are
+// configurable.
+type ConfigurableEmbedder interface {
+	AssetEmbedder
+	SetOptions(o Options)
+}
+
+// SetOptions sets the options used to generate the Go source file.
+func (e *embedder) SetOptions(o Options) {
+	e.options = o
+}
@@ -0,0 +1,53 @@
+package goembed
+
+// parallelData completes g with the information needed to make the
+// loading function of the generated Go source file decode the assets
+// concurrently, if the options ask so.
+func (e *embedder) parallelData(g *generatedFileData) {
+	if !e.options.Parallel {
+		return
+	}
+	g.Parallel = true
+	g.Table = true
+	g.Imports = mergeImports(g.Imports, "runtime", "sync", "sync/atomic")
+	if g.RawSuffix != "" {
+		g.Imports = mergeImports(g.Imports, "sort")
+	}
+}
+
+// parallelTemplate declares the function running the decode calls of
+// the loading function concurrently.
+const parallelTemplate = `
+// {{.FuncName}}Parallel calls f with each integer of [0, n), with up
+// to GOMAXPROCS concurrent calls, and returns the error of the first
+// of the failing calls, in that order.  No more calls are made once a
+// call fails.
+func {{.FuncName}}Parallel(n int, f func(i int) error) error {
+	errs := make([]error, n)
+	var next int64
+	var failed int32
+	var wg sync.WaitGroup
+	for w := 0; w < runtime.GOMAXPROCS(0) && w < n; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for atomic.LoadInt32(&failed) == 0 {
+				i := int(atomic.AddInt64(&next, 1) - 1)
+				if i >= n {
+					return
+				}
+				if errs[i] = f(i); errs[i] != nil {
+					atomic.StoreInt32(&failed, 1)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+`
@@ -0,0 +1,117 @@
+package goembed
+
+import (
+	"errors"
+	"sort"
+)
+
+// maxSeed bounds the search for the seed of a bucket of keys, which
+// only fails to terminate quickly for pathological key sets.
+const maxSeed = 1 << 24
+
+// hashKey returns the 64-bit FNV-1a hash of key.  The lookup
+// functions of the generated Go source files compute the same hash.
+func hashKey(key string) uint64 {
+	h := uint64(14695981039346656037)
+	for i := 0; i < len(key); i++ {
+		h ^= uint64(key[i])
+		h *= 1099511628211
+	}
+	return h
+}
+
+// mixHash derives a 32-bit hash from the hash h of a key and a seed,
+// using the SplitMix64 finalizer.  The lookup functions of the
+// generated Go source files compute the same hash.
+func mixHash(h uint64, seed uint32) uint32 {
+	h ^= uint64(seed) * 0x9e3779b97f4a7c15
+	h ^= h >> 30
+	h *= 0xbf58476d1ce4e5b9
+	h ^= h >> 27
+	h *= 0x94d049bb133111eb
+	h ^= h >> 31
+	return uint32(h)
+}
+
+// perfectHash computes a minimal perfect hash of keys, using the
+// hash and displace method.  The keys are distributed in
+// len(keys) buckets by their hash with seed 0.  Each bucket holding
+// several keys gets a seed under which its keys hash to distinct
+// free slots, and each bucket holding a single key gets a free slot
+// directly.  The slot of a key is then found using displace:
+//
+//	h := hashKey(key)
+//	d := displace[mixHash(h, 0)%n]
+//	if d < 0 {
+//		slot = -d - 1
+//	} else {
+//		slot = mixHash(h, uint32(d)) % n
+//	}
+//
+// perfectHash returns displace, and the slot of each of the keys,
+// which must be distinct.
+func perfectHash(keys []string) (displace []int32, slots []int, err error) {
+	n := uint32(len(keys))
+	hashes := make([]uint64, n)
+	buckets := make([][]int, n)
+	for i, k := range keys {
+		hashes[i] = hashKey(k)
+		b := mixHash(hashes[i], 0) % n
+		buckets[b] = append(buckets[b], i)
+	}
+	order := make([]int, n)
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return len(buckets[order[i]]) > len(buckets[order[j]])
+	})
+
+	displace = make([]int32, n)
+	slots = make([]int, n)
+	used := make([]bool, n)
+	var tried []int
+	next := 0
+	for _, b := range order {
+		bucket := buckets[b]
+		switch len(bucket) {
+		case 0:
+			continue
+		case 1:
+			// The remaining buckets hold a single key each,
+			// and there are as many free slots left.
+			for used[next] {
+				next++
+			}
+			used[next] = true
+			slots[bucket[0]] = next
+			displace[b] = int32(-next - 1)
+			continue
+		}
+		seed := uint32(1)
+	search:
+		for ; seed < maxSeed; seed++ {
+			tried = tried[:0]
+			for _, i := range bucket {
+				s := int(mixHash(hashes[i], seed) % n)
+				if used[s] {
+					for _, t := range tried {
+						used[t] = false
+					}
+					continue search
+				}
+				used[s] = true
+				tried = append(tried, s)
+			}
+			break
+		}
+		if seed == maxSeed {
+			return nil, nil, errors.New("goembed: cannot compute a perfect hash of the asset keys")
+		}
+		for j, i := range bucket {
+			slots[i] = tried[j]
+		}
+		displace[b] = int32(seed)
+	}
+	return displace, slots, nil
+}
@@ -11,13 +11,17 @@ import (
 )
 
 var (
-	imports = [...]string{}
+	imports       = [...]string{}
+	streamImports = [...]string{"io", "strings"}
 )
 
 const (
 	decode string = `func(s string) (string, error) {
 		return s, nil
 	}`
+	stream = `func(s string, off int64) (io.Reader, error) {
+		return strings.NewReader(s[off:]), nil
+	}`
 )
 
 func encode(contents io.Reader) (string, error) {
@@ -30,10 +34,18 @@ func encode(contents io.Reader) (string, error) {
 
 // NewSequential creates a new sequential quoteembedder asset embedder.
 func NewSequential() goembed.AssetEmbedder {
-	return goembed.NewSequentialEmbedder(encode, decode, imports[:])
+	e := goembed.NewSequentialEmbedder(encode, decode, imports[:])
+	e.SetName("quote")
+	e.SetLiteral()
+	e.SetStream(stream, streamImports[:]...)
+	return e
 }
 
 // NewConcurrent creates a new concurrent quoteembedder asset embedder.
 func NewConcurrent() goembed.AssetEmbedder {
-	return goembed.NewConcurrentEmbedder(encode, decode, imports[:])
+	e := goembed.NewConcurrentEmbedder(encode, decode, imports[:])
+	e.SetName("quote")
+	e.SetLiteral()
+	e.SetStream(stream, streamImports[:]...)
+	return e
 }
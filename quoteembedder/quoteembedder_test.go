@@ -3,6 +3,7 @@ package quoteembedder
 import (
 	"testing"
 
+	"github.com/jeanfric/goembed"
 	"github.com/jeanfric/goembed/embedtesting"
 )
 
@@ -13,3 +14,86 @@ func BenchmarkSequentialEmbedder(b *testing.B) {
 func BenchmarkConcurrentEmbedder(b *testing.B) {
 	embedtesting.BenchmarkEmbedder(b, NewConcurrent())
 }
+
+func table(ae goembed.AssetEmbedder) goembed.AssetEmbedder {
+	ae.(goembed.ConfigurableEmbedder).SetOptions(goembed.Options{Table: true})
+	return ae
+}
+
+func TestTableEmbedder(t *testing.T) {
+	embedtesting.TestEmbedder(t, table(NewSequential()))
+	embedtesting.TestEmbedderAssets(t, table(NewConcurrent()), map[string]string{
+		"/a":     "same",
+		"/b":     "same",
+		"/c":     "other",
+		"/empty": "",
+	})
+}
+
+// BenchmarkBuild stops at 1k assets: building 10k assets decoded
+// one statement after the other already takes minutes.
+func BenchmarkBuild(b *testing.B) {
+	embedtesting.BenchmarkBuild(b, NewConcurrent(), 1000)
+}
+
+func BenchmarkTableBuild(b *testing.B) {
+	embedtesting.BenchmarkBuild(b, table(NewConcurrent()), 100000)
+}
+
+func TestBytesEmbedder(t *testing.T) {
+	embedtesting.TestEmbedderBytes(t, NewSequential().(goembed.ConfigurableEmbedder), goembed.Options{})
+}
+
+func TestOpenEmbedder(t *testing.T) {
+	embedtesting.TestEmbedderOpen(t, NewSequential().(goembed.ConfigurableEmbedder), goembed.Options{})
+}
+
+func TestDigestsEmbedder(t *testing.T) {
+	embedtesting.TestEmbedderDigests(t, NewSequential().(goembed.ConfigurableEmbedder), goembed.Options{Blob: true})
+	embedtesting.TestEmbedderDigests(t, NewSequential().(goembed.ConfigurableEmbedder), goembed.Options{Hash: true})
+}
+
+func TestConstsEmbedder(t *testing.T) {
+	m := map[string]string{
+		"/img/gopher.png": "png",
+		"/index.html":     "html",
+		"/a-b":            "1",
+		"/a_b":            "2",
+		"/aB":             "3",
+		"/ab":             "4",
+		"/a.b/c":          "5",
+		"/a/b/c":          "6",
+		"/a//b":           "7",
+		"/a+b":            "8",
+		"/a\xffb":         "9",
+		"/.gitignore":     "10",
+		"/x/y.tar.gz":     "11",
+		"/names":          "12",
+	}
+	consts := map[string]string{
+		"Asset_img_gopher__dpng": "/img/gopher.png",
+		"Asset_index__dhtml":     "/index.html",
+		"Asset_a__hb":            "/a-b",
+		"Asset_a__ub":            "/a_b",
+		"Asset_aB":               "/aB",
+		"Asset_ab":               "/ab",
+		"Asset_a__db_c":          "/a.b/c",
+		"Asset_a_b_c":            "/a/b/c",
+		"Asset_a__s_b":           "/a//b",
+		"Asset_a__x2b_b":         "/a+b",
+		"Asset_a__bffb":          "/a\xffb",
+		"Asset__s__dgitignore":   "/.gitignore",
+		"Asset_x_y__dtar__dgz":   "/x/y.tar.gz",
+		"Asset_names":            "/names",
+	}
+	for _, o := range []goembed.Options{{}, {Blob: true}, {Solid: true}, {Open: "openAsset"}} {
+		embedtesting.TestEmbedderConsts(t, NewSequential().(goembed.ConfigurableEmbedder), o, m, consts)
+	}
+
+	// Removing an asset leaves the constants of the others alone.
+	delete(m, "/a-b")
+	delete(consts, "Asset_a__hb")
+	embedtesting.TestEmbedderConsts(t, NewSequential().(goembed.ConfigurableEmbedder), goembed.Options{}, m, consts)
+
+	embedtesting.TestEmbedderConstsError(t, NewSequential().(goembed.ConfigurableEmbedder), goembed.Options{Consts: "loadAssets"}, map[string]string{"Error": "e"}, "loadAssetsError", `"Error"`)
+}
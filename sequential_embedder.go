@@ -6,9 +6,7 @@ import (
 
 // A sequential embedder is an embedder that encodes assets one by one.
 type SequentialEmbedder struct {
-	encodeFunc func(contents io.Reader) (string, error)
-	decodeFunc string
-	imports    []string
+	embedder
 }
 
 // NewSequentialEmbedder creates a new sequential embedder that
@@ -17,10 +15,11 @@ type SequentialEmbedder struct {
 // package imports.  The decodeFunc will wrap each string
 // representation produced by the encodeFunc.
 //
-// The encodeFunc should return a string enclosed in its delimiters
-// (double quotes or backticks).  The embedder implementation can thus
-// choose if it wants to return a quoted string (with double quotes)
-// or a raw string (with backticks).
+// The encodeFunc is called with the *Asset being encoded, so it can
+// make use of its Key.  It should return a string enclosed in its
+// delimiters (double quotes or backticks).  The embedder
+// implementation can thus choose if it wants to return a quoted
+// string (with double quotes) or a raw string (with backticks).
 //
 // The decodeFunc must be written in this form:
 //
@@ -35,9 +34,11 @@ type SequentialEmbedder struct {
 // decodeFunc.
 func NewSequentialEmbedder(encodeFunc func(io.Reader) (string, error), decodeFunc string, imports []string) *SequentialEmbedder {
 	return &SequentialEmbedder{
-		encodeFunc: encodeFunc,
-		decodeFunc: decodeFunc,
-		imports:    imports,
+		embedder{
+			encodeFunc: encodeFunc,
+			decodeFunc: decodeFunc,
+			imports:    imports,
+		},
 	}
 }
 
@@ -48,25 +49,37 @@ func NewSequentialEmbedder(encodeFunc func(io.Reader) (string, error), decodeFun
 //
 // 	func funcName() (map[string]string, error)
 func (e *SequentialEmbedder) AssetEmbed(dst io.Writer, assets []*Asset, packageName, funcName string) (int, error) {
-	g := &generatedFileData{
-		PackageName: packageName,
-		FuncName:    funcName,
-		Imports:     e.imports,
-		DecodeFunc:  e.decodeFunc,
-		Assets:      make([]*processedAsset, len(assets)),
+	if e.options.Solid {
+		return e.embedSolid(dst, assets, packageName, funcName)
 	}
-	for i, a := range assets {
-		r, err := e.encodeFunc(a)
+	if e.options.Blob || e.options.Hash {
+		return e.embedBlob(dst, assets, packageName, funcName)
+	}
+
+	processed, prelude, encodeFunc, err := e.prepare(assets)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, p := range processed {
+		r, err := encodeFunc(p.Asset)
 		if err != nil {
 			return 0, err
 		}
+		p.EncodedRepresentation = r
+	}
 
-		g.Assets[i] = &processedAsset{
-			Asset: a,
-			EncodedRepresentation: r,
-		}
+	if err := e.writeAsm(funcName, processed); err != nil {
+		return 0, err
 	}
 
+	g := e.fileData(packageName, funcName, prelude, processed)
+	e.openData(g)
+	e.parallelData(g)
+	e.digestData(g, processed)
+	if err := e.constData(g, processed); err != nil {
+		return 0, err
+	}
 	n, err := generateEmbedFile(dst, g)
 	if err != nil {
 		return n, err
@@ -0,0 +1,284 @@
+package goembed
+
+import (
+	"sort"
+)
+
+// An openKey locates the distinct asset holding the contents of the
+// asset named Key.
+type openKey struct {
+	Key   string
+	Index int
+}
+
+// SetStream declares how the generated open function (see Options)
+// reads an asset without decoding it as a whole.  streamFunc must be
+// written in this form:
+//
+//	func(s string, off int64) (io.Reader, error) {
+//		// ...
+//	}
+//
+// The arguments are the encoded string, and the offset in the decoded
+// data from which to read, and the returned reader yields the decoded
+// data from that offset on.  The returned reader may end before the
+// asset does, such as at the end of a chunk of compressed data: the
+// rest of the asset is then read from the reader returned by another
+// call.  If the returned reader is an io.Closer, it is closed when it
+// is no longer needed.  streamFunc can refer to
+// the variables declared by the prelude of the embedder.  The imports
+// should match the compilation requirements of streamFunc.
+//
+// Embedders without a stream function are streamed by decoding each
+// asset as a whole when it is first read.
+func (e *embedder) SetStream(streamFunc string, imports ...string) {
+	e.streamFunc = streamFunc
+	e.streamImports = imports
+}
+
+// openData completes g with the information needed to produce the
+// open function of the generated Go source file, if the options name
+// one.
+func (e *embedder) openData(g *generatedFileData) {
+	if e.options.Open == "" {
+		return
+	}
+	g.OpenFunc = e.options.Open
+	g.StreamFunc = e.streamFunc
+	for i, a := range g.Assets {
+		for _, k := range a.Keys {
+			g.Keys = append(g.Keys, &openKey{
+				Key:   k,
+				Index: i,
+			})
+		}
+	}
+	sort.Slice(g.Keys, func(i, j int) bool {
+		return g.Keys[i].Key < g.Keys[j].Key
+	})
+	g.Imports = mergeImports(g.Imports, "errors", "io", "os", "sort")
+	switch {
+	case g.StreamFunc != "":
+		g.Imports = mergeImports(g.Imports, e.streamImports...)
+	case g.ValueType == "[]byte":
+		g.Imports = mergeImports(g.Imports, "bytes")
+	default:
+		g.Imports = mergeImports(g.Imports, "strings")
+	}
+}
+
+// openTablesTemplate declares the tables of the distinct encoded
+// assets, with the key of the first of them and their decoded size,
+// and of the asset keys, sorted by key, with the index of their
+// contents in the former.
+const openTablesTemplate = `
+var {{.FuncName}}Encoded = [...]struct {
+	key, s string
+	size   int64
+}{ {{- range $i, $v := .Assets}}
+	{ {{- printf "%q" $v.Key}}, {{$v.EncodedRepresentation}}, {{$v.Size}}},{{end}}
+}
+
+var {{.FuncName}}Keys = [...]struct {
+	key string
+	i   int
+}{ {{- range $i, $v := .Keys}}
+	{ {{- printf "%q" $v.Key}}, {{$v.Index}}},{{end}}
+}
+`
+
+// openAssetsTemplate is the body of a function that decodes each of
+// the distinct assets of the tables once, using the decode function
+// declared before it, and returns the results in a map.
+const openAssetsTemplate = `
+	decoded := make([]{{.ValueType}}, len({{.FuncName}}Encoded))
+{{- if .Parallel}}
+	err := {{.FuncName}}Parallel(len(decoded), func(i int) error {
+		e := {{.FuncName}}Encoded[i]
+		a, err := decode(e.s)
+		if err != nil {
+			return {{.FuncName}}Error(e.key, err)
+		}
+		decoded[i] = a
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+{{- else}}
+	for i, e := range {{.FuncName}}Encoded {
+		a, err := decode(e.s)
+		if err != nil {
+			return nil, {{.FuncName}}Error(e.key, err)
+		}
+		decoded[i] = a
+	}
+{{- end}}
+	assets := make(map[string]{{.ValueType}}, len({{.FuncName}}Keys))
+{{- if eq .ValueType "[]byte"}}
+	// Each key gets its own copy, which can be written to.
+	used := make([]bool, len(decoded))
+	for _, k := range {{.FuncName}}Keys {
+		if used[k.i] {
+			assets[k.key] = append([]byte(nil), decoded[k.i]...)
+			continue
+		}
+		used[k.i] = true
+		assets[k.key] = decoded[k.i]
+	}
+{{- else}}
+	for _, k := range {{.FuncName}}Keys {
+		assets[k.key] = decoded[k.i]
+	}
+{{- end}}` + verifyTemplate + `
+	return assets, nil
+}
+`
+
+// openFuncTemplate declares the open function, and the type of the
+// streams it returns.  Without a stream function, an asset is decoded
+// as a whole when it is first read.  Seeking forward skips the
+// decoded data, and seeking backward decodes the asset again from
+// the offset sought, and so does ReadAt.
+const openFuncTemplate = `
+// {{.OpenFunc}} opens the asset named name for reading.  The asset
+// is decoded as it is read, rather than as a whole.  The returned
+// stream also implements io.ReaderAt.
+func {{.OpenFunc}}(name string{{if .Params}}, {{.Params}}{{end}}) (io.ReadSeekCloser, error) {
+{{- if .StreamFunc}}
+{{if .Prelude}}	{{.Prelude}}
+{{end}}	stream := {{.StreamFunc}}
+{{- else}}
+` + decodeTemplate + `{{if .RawSuffix}}	rawDecode := {{.RawDecodeFunc}}
+{{end}}	stream := func(s string, off int64) (io.Reader, error) {
+{{- if .RawSuffix}}
+		s, err := rawDecode(s)
+		if err != nil {
+			return nil, err
+		}
+{{- end}}
+		a, err := decode(s)
+		if err != nil {
+			return nil, err
+		}
+		return {{if eq .ValueType "[]byte"}}bytes{{else}}strings{{end}}.NewReader(a[off:]), nil
+	}
+{{- end}}
+	i := sort.Search(len({{.FuncName}}Keys), func(i int) bool {
+		return {{.FuncName}}Keys[i].key >= name
+	})
+	if i == len({{.FuncName}}Keys) || {{.FuncName}}Keys[i].key != name {
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+	}
+	e := {{.FuncName}}Encoded[{{.FuncName}}Keys[i].i]
+	return &{{.FuncName}}Stream{
+		open: func(off int64) (io.Reader, error) {
+			r, err := stream(e.s, off)
+			if err != nil {
+				return nil, {{.FuncName}}Error(name, err)
+			}
+			return r, nil
+		},
+		name: name,
+		size: e.size,
+	}, nil
+}
+
+// A {{.FuncName}}Stream reads an asset, decoding it from the position
+// it is read at.
+type {{.FuncName}}Stream struct {
+	open      func(off int64) (io.Reader, error)
+	name      string
+	r         io.Reader
+	start     int64 // The position r was opened at
+	pos, size int64
+}
+
+func (s *{{.FuncName}}Stream) Read(p []byte) (int, error) {
+	if s.pos >= s.size {
+		return 0, io.EOF
+	}
+	if s.r == nil {
+		r, err := s.open(s.pos)
+		if err != nil {
+			return 0, err
+		}
+		s.r = r
+		s.start = s.pos
+	}
+	if rest := s.size - s.pos; int64(len(p)) > rest {
+		p = p[:rest]
+	}
+	n, err := s.r.Read(p)
+	s.pos += int64(n)
+	if err != nil && err != io.EOF {
+		return n, {{.FuncName}}Error(s.name, err)
+	}
+	if err == io.EOF && s.pos < s.size {
+		if s.pos == s.start {
+			return n, io.ErrUnexpectedEOF
+		}
+		// The rest of the asset is read from another reader.
+		if err = s.Close(); err == nil && n == 0 {
+			return s.Read(p)
+		}
+	}
+	return n, err
+}
+
+func (s *{{.FuncName}}Stream) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("ReadAt: negative offset")
+	}
+	t := &{{.FuncName}}Stream{open: s.open, name: s.name, pos: off, size: s.size}
+	defer t.Close()
+	n, err := io.ReadFull(t, p)
+	if err == io.ErrUnexpectedEOF && off+int64(n) == s.size {
+		err = io.EOF
+	}
+	return n, err
+}
+
+func (s *{{.FuncName}}Stream) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += s.pos
+	case io.SeekEnd:
+		offset += s.size
+	default:
+		return 0, errors.New("Seek: invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("Seek: negative position")
+	}
+	if s.r != nil && offset > s.pos && offset < s.size {
+		// Skip the decoded data up to offset, unless the
+		// reader ends before.
+		if _, err := io.CopyN(io.Discard, s.r, offset-s.pos); err != nil {
+			if cerr := s.Close(); err == io.EOF {
+				err = cerr
+			}
+			if err != nil {
+				return 0, {{.FuncName}}Error(s.name, err)
+			}
+		}
+	} else if offset != s.pos {
+		// Decode again from offset when reading.
+		if err := s.Close(); err != nil {
+			return 0, err
+		}
+	}
+	s.pos = offset
+	return offset, nil
+}
+
+func (s *{{.FuncName}}Stream) Close() error {
+	var err error
+	if c, ok := s.r.(io.Closer); ok {
+		err = c.Close()
+	}
+	s.r = nil
+	return err
+}
+`
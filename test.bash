@@ -6,7 +6,7 @@ function timecmd {
     { time -p "$@" ; } 2>&1 | tail -n 3 | grep real | cut -f2 -d' '
 }
 
-all_embedders="zhex zbase64 hex base64 quote"
+all_embedders="zhex zbase64 zdictbase64 gzbase64 zbz2base64 lzwbase64 lz4base64 lzmabase64 hex base64 quote cquote ascii85 zascii85 aesgcm"
 
 embedders=$@
 if [ "" == "$embedders" ]; then
@@ -27,33 +27,63 @@ for e in $embedders; do
     go test -bench=. -cpu 1,4 -benchtime 5s
     popd >/dev/null
 
-    echo "# $e: goembed"
-    pushd cmd/goembedtest >/dev/null
-    wdir="$(mktemp -d)"
-    # Amplify the size of the test data
-    for i in `seq 0 63`; do
-	cp -r testdata "$wdir/$i"
+    modes="-solid=false -solid -table -asm=0 -type=bytes -open=openAsset -parallel -verify -consts=Asset"
+    flags=""
+    case $e in
+	gzbase64)
+	    modes="-solid=false -table -asm=0 -type=bytes -open=openAsset -parallel -verify -consts=Asset"
+	    ;;
+	quote|cquote)
+	    modes="$modes -blob -hash"
+	    ;;
+	zbase64|zhex)
+	    modes="$modes -chunk=65536"
+	    ;;
+	aesgcm)
+	    # The loading function reads the key from the environment
+	    export GOEMBED_KEY="$(head -c 32 /dev/urandom | od -An -tx1 | tr -d ' \n')"
+	    flags="-keyenv=GOEMBED_KEY"
+	    ;;
+    esac
+    for mode in $modes; do
+	echo "# $e $mode: goembed"
+	pushd cmd/goembedtest >/dev/null
+	wdir="$(mktemp -d)"
+	# Amplify the size of the test data.  Appending the copy number
+	# to the files keeps the copies from being deduplicated.
+	for i in `seq 0 63`; do
+	    cp -r testdata "$wdir/$i"
+	    if [ $i -gt 0 ]; then
+		find "$wdir/$i" -type f -exec sh -c 'echo "$0" >> "$1"' $i {} \;
+	    fi
+	done
+	# An identical copy is reported as duplicates
+	cp -r testdata "$wdir/dup"
+	../goembed/goembed -c=true -e $e $flags $mode "$wdir" 2>"$wdir.log" || { cat "$wdir.log"; exit 1; }
+	echo -e "dups\t$(grep -c ' duplicate of ' "$wdir.log")"
+	rm "$wdir.log"
+	echo -e "size\t$(du -h assets.generated.go | cut -f1)"
+	gofmt assets.generated.go > assets.generated.go.gofmt
+	diff -u assets.generated.go assets.generated.go.gofmt
+	rm assets.generated.go.gofmt
+	echo "# $e $mode: goembedtest"
+	go clean
+	go build
+	go clean
+	t="$(timecmd go build)"
+	echo -e "build\tgoembedtest\t${t}s"
+	./goembedtest "$wdir"
+	echo -ne "time\t"
+	for i in `seq 1 5`; do
+	    t="$(timecmd ./goembedtest -q '$wdir')"
+	    echo -n "${t}s  "
+	done
+	echo
+	# Loaders generated with -parallel use all the cores
+	echo -e "$(GOMAXPROCS=1 ./goembedtest -q -t "$wdir")\n$(./goembedtest -q -t "$wdir")"
+	rm -rf "$wdir" assets.generated.s
+	popd >/dev/null
     done
-    ../goembed/goembed -c=true -e $e "$wdir"
-    echo -e "size\t$(du -h assets.generated.go | cut -f1)"
-    gofmt assets.generated.go > assets.generated.go.gofmt
-    diff -u assets.generated.go assets.generated.go.gofmt
-    rm assets.generated.go.gofmt
-    echo "# $e: goembedtest"
-    go clean
-    go build
-    go clean
-    t="$(timecmd go build)"
-    echo -e "build\tgoembedtest\t${t}s"
-    ./goembedtest "$wdir"
-    echo -ne "time\t"
-    for i in `seq 1 5`; do
-	t="$(timecmd ./goembedtest -q '$wdir')"
-	echo -n "${t}s  "
-    done
-    echo
-    rm -rf "$wdir"
-    popd >/dev/null
 
     echo
 done
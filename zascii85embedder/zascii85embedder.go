@@ -0,0 +1,72 @@
+// Package zascii85embedder implements an asset embedder that
+// compresses assets using zlib, then encodes the resulting data as
+// ascii85 strings.
+//
+// The ascii85 alphabet contains the double quote, backslash and
+// backtick characters, so the encoded data is written using the
+// shortest combination of raw and interpreted string literals (see
+// cquoteembedder.Quote).
+package zascii85embedder
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/ascii85"
+	"io"
+
+	"github.com/jeanfric/goembed"
+	"github.com/jeanfric/goembed/cquoteembedder"
+)
+
+var (
+	imports = [...]string{"compress/zlib", "encoding/ascii85", "io/ioutil", "strings"}
+)
+
+const (
+	decode = `func(s string) (string, error) {
+		r, err := zlib.NewReader(ascii85.NewDecoder(strings.NewReader(s)))
+		if err != nil {
+			return "", err
+		}
+		defer r.Close()
+		ob, err := ioutil.ReadAll(r)
+		if err != nil {
+			return "", err
+		}
+		return string(ob), nil
+	}`
+)
+
+func encode(contents io.Reader) (string, error) {
+	var ab bytes.Buffer
+	aw := ascii85.NewEncoder(&ab)
+	w := zlib.NewWriter(aw)
+	_, err := io.Copy(w, contents)
+	if err != nil {
+		return "", err
+	}
+	err = w.Close()
+	if err != nil {
+		return "", err
+	}
+	err = aw.Close()
+	if err != nil {
+		return "", err
+	}
+
+	return cquoteembedder.Quote(ab.Bytes()), nil
+}
+
+// NewSequential creates a new sequential zascii85embedder asset embedder.
+func NewSequential() goembed.AssetEmbedder {
+	e := goembed.NewSequentialEmbedder(encode, decode, imports[:])
+	e.SetName("zascii85")
+	return e
+}
+
+// NewConcurrent creates a new concurrent zascii85embedder asset embedder.
+func NewConcurrent() goembed.AssetEmbedder {
+	e := goembed.NewConcurrentEmbedder(encode, decode, imports[:])
+	e.SetName("zascii85")
+	return e
+}
@@ -1,19 +1,27 @@
 // Package zbase64embedder implements an asset embedder that
 // compresses assets using zlib, then encodes the resulting data as
 // base64 strings.
+//
+// Assets that zlib does not make smaller, such as images, are stored
+// uncompressed.  The length of each asset is recorded with its data,
+// so the decoder rejects data that decompresses to more or fewer
+// bytes, such as decompression bombs.  Large assets can be compressed
+// in chunks, so that the open function of the generated Go source
+// file decompresses only the chunks that are read.
 package zbase64embedder
 
 import (
-	"bytes"
 	"compress/zlib"
 	"encoding/base64"
 	"io"
 
 	"github.com/jeanfric/goembed"
+	"github.com/jeanfric/goembed/zlibcompress"
 )
 
 var (
-	imports = [...]string{"bytes", "compress/zlib", "encoding/base64", "io/ioutil"}
+	imports       = [...]string{"bytes", "compress/zlib", "encoding/binary", "encoding/base64", "errors", "io", "io/ioutil"}
+	streamImports = [...]string{"compress/zlib", "encoding/binary", "encoding/base64", "errors", "io", "strings"}
 )
 
 const (
@@ -22,40 +30,203 @@ const (
 		if err != nil {
 			return "", err
 		}
-		r, err := zlib.NewReader(bytes.NewReader(b))
-		if err != nil {
-			return "", err
+		if len(b) == 0 {
+			return "", errors.New("missing header")
+		}
+		// inflate decompresses the zlib stream b, and checks
+		// that it holds exactly n bytes.
+		inflate := func(b []byte, n int64) ([]byte, error) {
+			br := bytes.NewReader(b)
+			r, err := zlib.NewReader(br)
+			if err != nil {
+				return nil, err
+			}
+			defer r.Close()
+			ob, err := ioutil.ReadAll(io.LimitReader(r, n+1))
+			switch {
+			case err != nil:
+				return nil, err
+			case int64(len(ob)) > n:
+				return nil, errors.New("decompressed data longer than recorded")
+			case int64(len(ob)) < n:
+				return nil, errors.New("decompressed data shorter than recorded")
+			case br.Len() > 0:
+				return nil, errors.New("trailing data after compressed data")
+			}
+			return ob, nil
+		}
+		switch b[0] {
+		case 0:
+			// Stored uncompressed.
+			return string(b[1:]), nil
+		case 1:
+			// Compressed in chunks.
+			if len(b) < 13 {
+				return "", errors.New("invalid chunk header")
+			}
+			size := int64(binary.BigEndian.Uint32(b[1:]))
+			chunk := int64(binary.BigEndian.Uint32(b[5:]))
+			n := int64(binary.BigEndian.Uint32(b[9:]))
+			// Deflate cannot expand data more than 1032 times.
+			if chunk == 0 || n != (size+chunk-1)/chunk || int64(len(b)) < 17+4*n || size > 1032*int64(len(b)) {
+				return "", errors.New("invalid chunk header")
+			}
+			if int64(binary.BigEndian.Uint32(b[13:])) != 17+4*n {
+				return "", errors.New("invalid chunk header")
+			}
+			if int64(binary.BigEndian.Uint32(b[13+4*n:])) < int64(len(b)) {
+				return "", errors.New("trailing data after compressed data")
+			}
+			ob := make([]byte, 0, size)
+			for i := int64(0); i < n; i++ {
+				start := binary.BigEndian.Uint32(b[13+4*i:])
+				end := binary.BigEndian.Uint32(b[17+4*i:])
+				if start > end || int64(end) > int64(len(b)) {
+					return "", errors.New("invalid chunk header")
+				}
+				length := chunk
+				if i == n-1 {
+					length = size - i*chunk
+				}
+				cb, err := inflate(b[start:end], length)
+				if err != nil {
+					return "", err
+				}
+				ob = append(ob, cb...)
+			}
+			return string(ob), nil
+		case 2:
+			// Compressed.
+			if len(b) < 5 {
+				return "", errors.New("invalid header")
+			}
+			ob, err := inflate(b[5:], int64(binary.BigEndian.Uint32(b[1:])))
+			if err != nil {
+				return "", err
+			}
+			return string(ob), nil
+		}
+		return "", errors.New("unknown compression format")
+	}`
+
+	// Stored assets are read from off.  Compressed assets are
+	// inflated from the beginning of the chunk holding off if they
+	// are compressed in chunks, or else from their beginning.
+	stream = `func(s string, off int64) (io.Reader, error) {
+		at := func(i int64) (io.Reader, error) {
+			r := base64.NewDecoder(base64.StdEncoding, strings.NewReader(s[i/3*4:]))
+			if _, err := io.CopyN(io.Discard, r, i%3); err != nil {
+				return nil, err
+			}
+			return r, nil
+		}
+		read := func(i, n int64) ([]byte, error) {
+			r, err := at(i)
+			if err != nil {
+				return nil, err
+			}
+			b := make([]byte, n)
+			_, err = io.ReadFull(r, b)
+			return b, err
 		}
-		defer r.Close()
-		ob, err := ioutil.ReadAll(r)
+		inflate := func(r io.Reader, off int64) (io.Reader, error) {
+			z, err := zlib.NewReader(r)
+			if err != nil {
+				return nil, err
+			}
+			if _, err := io.CopyN(io.Discard, z, off); err != nil {
+				z.Close()
+				return nil, err
+			}
+			return z, nil
+		}
+		flag, err := read(0, 1)
 		if err != nil {
-			return "", err
+			return nil, err
 		}
-		return string(ob), nil
+		switch flag[0] {
+		case 0:
+			// Stored uncompressed.
+			return at(off + 1)
+		case 1:
+			// Compressed in chunks: only the chunk holding
+			// off is inflated, up to its end.
+			h, err := read(5, 4)
+			if err != nil {
+				return nil, err
+			}
+			size := int64(binary.BigEndian.Uint32(h))
+			if size == 0 {
+				return nil, errors.New("invalid chunk size")
+			}
+			i := off / size
+			h, err = read(13+4*i, 8)
+			if err != nil {
+				return nil, err
+			}
+			start := int64(binary.BigEndian.Uint32(h))
+			end := int64(binary.BigEndian.Uint32(h[4:]))
+			r, err := at(start)
+			if err != nil {
+				return nil, err
+			}
+			return inflate(io.LimitReader(r, end-start), off-i*size)
+		case 2:
+			// Compressed.
+			r, err := at(5)
+			if err != nil {
+				return nil, err
+			}
+			return inflate(r, off)
+		}
+		return nil, errors.New("unknown compression format")
 	}`
 )
 
-func encode(contents io.Reader) (string, error) {
-	var zb bytes.Buffer
-	w := zlib.NewWriter(&zb)
-	_, err := io.Copy(w, contents)
-	if err != nil {
-		return "", err
-	}
-	err = w.Close()
-	if err != nil {
-		return "", err
+// encoder returns an encoding function that compresses assets using
+// c.
+func encoder(c *zlibcompress.Compressor) func(io.Reader) (string, error) {
+	return func(contents io.Reader) (string, error) {
+		zb, err := c.Compress(contents)
+		if err != nil {
+			return "", err
+		}
+		return "`" + base64.StdEncoding.EncodeToString(zb) + "`", nil
 	}
-
-	return "`" + base64.StdEncoding.EncodeToString(zb.Bytes()) + "`", nil
 }
 
 // NewSequential creates a new sequential zbase64embedder asset embedder.
 func NewSequential() goembed.AssetEmbedder {
-	return goembed.NewSequentialEmbedder(encode, decode, imports[:])
+	c, _ := zlibcompress.New(zlib.DefaultCompression)
+	e := goembed.NewSequentialEmbedder(encoder(c), decode, imports[:])
+	e.SetName("zbase64")
+	e.SetStream(stream, streamImports[:]...)
+	return e
 }
 
 // NewConcurrent creates a new concurrent zbase64embedder asset embedder.
 func NewConcurrent() goembed.AssetEmbedder {
-	return goembed.NewConcurrentEmbedder(encode, decode, imports[:])
+	c, _ := zlibcompress.New(zlib.DefaultCompression)
+	e := goembed.NewConcurrentEmbedder(encoder(c), decode, imports[:])
+	e.SetName("zbase64")
+	e.SetStream(stream, streamImports[:]...)
+	return e
+}
+
+// NewSequentialCompressor creates a new sequential zbase64embedder asset
+// embedder that compresses assets using c.
+func NewSequentialCompressor(c *zlibcompress.Compressor) goembed.AssetEmbedder {
+	e := goembed.NewSequentialEmbedder(encoder(c), decode, imports[:])
+	e.SetName("zbase64")
+	e.SetStream(stream, streamImports[:]...)
+	return e
+}
+
+// NewConcurrentCompressor creates a new concurrent zbase64embedder asset
+// embedder that compresses assets using c.
+func NewConcurrentCompressor(c *zlibcompress.Compressor) goembed.AssetEmbedder {
+	e := goembed.NewConcurrentEmbedder(encoder(c), decode, imports[:])
+	e.SetName("zbase64")
+	e.SetStream(stream, streamImports[:]...)
+	return e
 }
@@ -1,9 +1,16 @@
 package zbase64embedder
 
 import (
+	"bytes"
+	"compress/zlib"
+	"encoding/base64"
+	"fmt"
+	"strings"
 	"testing"
 
+	"github.com/jeanfric/goembed"
 	"github.com/jeanfric/goembed/embedtesting"
+	"github.com/jeanfric/goembed/zlibcompress"
 )
 
 func BenchmarkSequentialEmbedder(b *testing.B) {
@@ -13,3 +20,139 @@ func BenchmarkSequentialEmbedder(b *testing.B) {
 func BenchmarkConcurrentEmbedder(b *testing.B) {
 	embedtesting.BenchmarkEmbedder(b, NewConcurrent())
 }
+
+func BenchmarkDecoder(b *testing.B) {
+	embedtesting.BenchmarkDecoder(b, NewSequential())
+}
+
+func solid(ae goembed.AssetEmbedder) goembed.AssetEmbedder {
+	ae.(goembed.ConfigurableEmbedder).SetOptions(goembed.Options{Solid: true})
+	return ae
+}
+
+func TestSolidEmbedder(t *testing.T) {
+	embedtesting.TestEmbedder(t, solid(NewSequential()))
+	embedtesting.TestEmbedder(t, solid(NewConcurrent()))
+}
+
+func BenchmarkSolidEmbedder(b *testing.B) {
+	embedtesting.BenchmarkEmbedder(b, solid(NewSequential()))
+}
+
+func BenchmarkSolidDecoder(b *testing.B) {
+	embedtesting.BenchmarkDecoder(b, solid(NewSequential()))
+}
+
+func parallel(ae goembed.AssetEmbedder) goembed.AssetEmbedder {
+	ae.(goembed.ConfigurableEmbedder).SetOptions(goembed.Options{Parallel: true})
+	return ae
+}
+
+func TestParallelEmbedder(t *testing.T) {
+	embedtesting.TestEmbedder(t, parallel(NewSequential()))
+	embedtesting.TestEmbedderBytes(t, NewConcurrent().(goembed.ConfigurableEmbedder), goembed.Options{Parallel: true})
+	embedtesting.TestEmbedderOpen(t, NewSequential().(goembed.ConfigurableEmbedder), goembed.Options{Parallel: true})
+}
+
+func BenchmarkParallelDecoder(b *testing.B) {
+	embedtesting.BenchmarkDecoder(b, parallel(NewSequential()))
+}
+
+func TestDuplicateAssets(t *testing.T) {
+	license := strings.Repeat("Permission is hereby granted, free of charge. ", 50)
+	m := map[string]string{
+		"/LICENSE":          license,
+		"/a/LICENSE":        license,
+		"/b/c/LICENSE":      license,
+		"/README":           "read me",
+		"/empty":            "",
+		"/also/empty":       "",
+		"/not/quite/README": "read me!",
+	}
+	for _, ae := range []goembed.AssetEmbedder{NewSequential(), NewConcurrent(), solid(NewSequential())} {
+		embedtesting.TestEmbedderAssets(t, ae, m)
+	}
+
+	var log, src bytes.Buffer
+	ae := NewSequential()
+	ae.(goembed.ConfigurableEmbedder).SetOptions(goembed.Options{Log: &log})
+	if _, err := ae.AssetEmbed(&src, embedtesting.AssetsFromMap(m), "main", "loadAssets"); err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(src.String(), "decode(`"); n != 4 {
+		t.Errorf("got %d encoded assets, want 4", n)
+	}
+	if n := strings.Count(log.String(), " bytes saved\n"); n != 3 {
+		t.Errorf("got %d duplicates reported, want 3:\n%s", n, log.String())
+	}
+	if want := fmt.Sprintf("duplicate of /LICENSE, %d bytes saved\n", len(license)); !strings.Contains(log.String(), want) {
+		t.Errorf("log %q does not contain %q", log.String(), want)
+	}
+}
+
+func TestAsmEmbedder(t *testing.T) {
+	for _, o := range []goembed.Options{
+		{AsmThreshold: 1000},
+		{AsmThreshold: 0, Table: true},
+		{AsmThreshold: 1000, Solid: true},
+	} {
+		embedtesting.TestEmbedderAsm(t, NewSequential().(goembed.ConfigurableEmbedder), o)
+	}
+}
+
+func TestBytesEmbedder(t *testing.T) {
+	embedtesting.TestEmbedderBytes(t, NewSequential().(goembed.ConfigurableEmbedder), goembed.Options{})
+	embedtesting.TestEmbedderBytes(t, NewSequential().(goembed.ConfigurableEmbedder), goembed.Options{Solid: true})
+	embedtesting.TestEmbedderBytes(t, NewConcurrent().(goembed.ConfigurableEmbedder), goembed.Options{Table: true})
+	embedtesting.TestEmbedderBytes(t, NewConcurrent().(goembed.ConfigurableEmbedder), goembed.Options{Open: "openAsset"})
+}
+
+func TestOpenEmbedder(t *testing.T) {
+	embedtesting.TestEmbedderOpen(t, NewSequential().(goembed.ConfigurableEmbedder), goembed.Options{})
+}
+
+func TestOpenBytesEmbedder(t *testing.T) {
+	embedtesting.TestEmbedderOpen(t, NewSequential().(goembed.ConfigurableEmbedder), goembed.Options{Bytes: true})
+}
+
+func TestDigestsEmbedder(t *testing.T) {
+	embedtesting.TestEmbedderDigests(t, NewSequential().(goembed.ConfigurableEmbedder), goembed.Options{})
+	embedtesting.TestEmbedderDigests(t, NewConcurrent().(goembed.ConfigurableEmbedder), goembed.Options{Solid: true})
+	embedtesting.TestEmbedderDigests(t, NewSequential().(goembed.ConfigurableEmbedder), goembed.Options{Parallel: true, Bytes: true})
+	embedtesting.TestEmbedderDigests(t, NewSequential().(goembed.ConfigurableEmbedder), goembed.Options{Open: "openAsset"})
+}
+
+func TestDecodeError(t *testing.T) {
+	c, err := zlibcompress.New(zlib.DefaultCompression)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := encoder(c)(strings.NewReader(embedtesting.GetTestAsset("/README")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, o := range []goembed.Options{{}, {Table: true}, {Parallel: true}, {Open: "openAsset"}} {
+		embedtesting.TestEmbedderError(t, NewSequential().(goembed.ConfigurableEmbedder), o, r, "`!!!!`", "/README: zbase64: illegal base64 data")
+	}
+}
+
+// chunked returns an embedder created by newEmbedder, compressing the
+// assets in chunks of 4096 bytes.
+func chunked(newEmbedder func(*zlibcompress.Compressor) goembed.AssetEmbedder) goembed.ConfigurableEmbedder {
+	c, err := zlibcompress.New(zlib.DefaultCompression)
+	if err != nil {
+		panic(err)
+	}
+	c.SetChunkSize(4096)
+	return newEmbedder(c).(goembed.ConfigurableEmbedder)
+}
+
+func TestChunkedEmbedder(t *testing.T) {
+	embedtesting.TestEmbedder(t, chunked(NewSequentialCompressor))
+	embedtesting.TestEmbedderBytes(t, chunked(NewConcurrentCompressor), goembed.Options{})
+	embedtesting.TestEmbedderOpen(t, chunked(NewSequentialCompressor), goembed.Options{})
+}
+
+func TestDecoder(t *testing.T) {
+	embedtesting.TestDecoder(t, decode, imports[:], embedtesting.ZlibDecoderTests(base64.StdEncoding.EncodeToString))
+}
@@ -0,0 +1,64 @@
+// Package zbz2base64embedder implements an asset embedder that
+// compresses assets using bzip2, then encodes the resulting data as
+// base64 strings.
+//
+// The standard Go library can only decompress bzip2 data, so the
+// assets are compressed using package bzip2writer.  The generated
+// code decompresses them using package compress/bzip2.
+package zbz2base64embedder
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+
+	"github.com/jeanfric/goembed"
+	"github.com/jeanfric/goembed/bzip2writer"
+)
+
+var (
+	imports = [...]string{"bytes", "compress/bzip2", "encoding/base64", "io/ioutil"}
+)
+
+const (
+	decode = `func(s string) (string, error) {
+		b, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return "", err
+		}
+		ob, err := ioutil.ReadAll(bzip2.NewReader(bytes.NewReader(b)))
+		if err != nil {
+			return "", err
+		}
+		return string(ob), nil
+	}`
+)
+
+func encode(contents io.Reader) (string, error) {
+	var zb bytes.Buffer
+	w := bzip2writer.New(&zb)
+	_, err := io.Copy(w, contents)
+	if err != nil {
+		return "", err
+	}
+	err = w.Close()
+	if err != nil {
+		return "", err
+	}
+
+	return "`" + base64.StdEncoding.EncodeToString(zb.Bytes()) + "`", nil
+}
+
+// NewSequential creates a new sequential zbz2base64embedder asset embedder.
+func NewSequential() goembed.AssetEmbedder {
+	e := goembed.NewSequentialEmbedder(encode, decode, imports[:])
+	e.SetName("zbz2base64")
+	return e
+}
+
+// NewConcurrent creates a new concurrent zbz2base64embedder asset embedder.
+func NewConcurrent() goembed.AssetEmbedder {
+	e := goembed.NewConcurrentEmbedder(encode, decode, imports[:])
+	e.SetName("zbz2base64")
+	return e
+}
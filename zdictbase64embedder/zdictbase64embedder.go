@@ -0,0 +1,178 @@
+// Package zdictbase64embedder implements an asset embedder that
+// compresses assets using DEFLATE with a preset dictionary shared by
+// all the assets, then encodes the resulting data as base64 strings.
+//
+// Bundles made of many small, similar assets (such as JSON or HTML
+// fragments) compress poorly one asset at a time, since each
+// compressed stream starts without any knowledge of the data.  The
+// preset dictionary is built from the substrings that occur in the
+// most assets, is embedded once in the generated code, and primes the
+// compression of each asset, which can still be decoded on its own.
+package zdictbase64embedder
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"sort"
+
+	"github.com/jeanfric/goembed"
+	"github.com/jeanfric/goembed/cquoteembedder"
+)
+
+var (
+	imports = [...]string{"bytes", "compress/flate", "encoding/base64", "io/ioutil"}
+)
+
+const (
+	decode = `func(s string) (string, error) {
+		b, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return "", err
+		}
+		r := flate.NewReaderDict(bytes.NewReader(b), dict)
+		defer r.Close()
+		ob, err := ioutil.ReadAll(r)
+		if err != nil {
+			return "", err
+		}
+		return string(ob), nil
+	}`
+)
+
+const (
+	// dictSize is the maximum size of the dictionary, which is the
+	// size of the DEFLATE window.
+	dictSize = 32 * 1024
+
+	// segmentSize is the size of the pieces of assets the
+	// dictionary is made of.
+	segmentSize = 64
+
+	// dmerSize is the size of the substrings whose frequencies are
+	// used to score segments.
+	dmerSize = 8
+)
+
+// buildDictionary builds a preset dictionary out of the segments of
+// contents made of the substrings found in the most assets.
+//
+// The assets are split into as many consecutive epochs as there are
+// segments in the dictionary.  The best segment of each epoch is
+// selected, and the substrings it contains no longer count towards
+// the score of the following segments.  The best segments are placed
+// at the end of the dictionary, where they can be referred to using
+// the shortest distances.
+func buildDictionary(contents [][]byte) []byte {
+	// Count the number of assets each substring appears in.
+	freqs := make(map[uint64]int)
+	var total int
+	for _, b := range contents {
+		seen := make(map[uint64]bool)
+		for i := 0; i+dmerSize <= len(b); i++ {
+			d := binary.LittleEndian.Uint64(b[i:])
+			if !seen[d] {
+				seen[d] = true
+				freqs[d]++
+			}
+		}
+		total += len(b)
+	}
+	score := func(d uint64) int {
+		// Substrings found in a single asset are better left to
+		// the compression of that asset.
+		if f := freqs[d]; f > 1 {
+			return f
+		}
+		return 0
+	}
+
+	type segment struct {
+		data  []byte
+		score int
+	}
+	var segments []segment
+	epochs := dictSize / segmentSize
+	epochSize := total/epochs + 1
+	if epochSize < segmentSize {
+		epochSize = segmentSize
+	}
+	for _, b := range contents {
+		for start := 0; start+segmentSize <= len(b); start += epochSize {
+			end := start + epochSize
+			if end > len(b) {
+				end = len(b)
+			}
+			best, bestScore, s := 0, 0, 0
+			for i := start; i+dmerSize <= end; i++ {
+				s += score(binary.LittleEndian.Uint64(b[i:]))
+				if i-start >= segmentSize-dmerSize+1 {
+					s -= score(binary.LittleEndian.Uint64(b[i-segmentSize+dmerSize-1:]))
+				}
+				if i+dmerSize-segmentSize >= start && s > bestScore {
+					best, bestScore = i+dmerSize-segmentSize, s
+				}
+			}
+			if bestScore == 0 {
+				continue
+			}
+			seg := b[best : best+segmentSize]
+			segments = append(segments, segment{seg, bestScore})
+			for i := 0; i+dmerSize <= len(seg); i++ {
+				delete(freqs, binary.LittleEndian.Uint64(seg[i:]))
+			}
+		}
+	}
+
+	sort.SliceStable(segments, func(i, j int) bool {
+		return segments[i].score > segments[j].score
+	})
+	if len(segments) > epochs {
+		segments = segments[:epochs]
+	}
+	var dict []byte
+	for i := len(segments) - 1; i >= 0; i-- {
+		dict = append(dict, segments[i].data...)
+	}
+	return dict
+}
+
+func prepare(contents [][]byte) (string, func(io.Reader) (string, error), error) {
+	dict := buildDictionary(contents)
+	encode := func(contents io.Reader) (string, error) {
+		var zb bytes.Buffer
+		w, err := flate.NewWriterDict(&zb, flate.DefaultCompression, dict)
+		if err != nil {
+			return "", err
+		}
+		_, err = io.Copy(w, contents)
+		if err != nil {
+			return "", err
+		}
+		err = w.Close()
+		if err != nil {
+			return "", err
+		}
+
+		return "`" + base64.StdEncoding.EncodeToString(zb.Bytes()) + "`", nil
+	}
+	return "dict := []byte(" + cquoteembedder.Quote(dict) + ")", encode, nil
+}
+
+// NewSequential creates a new sequential zdictbase64embedder asset embedder.
+func NewSequential() goembed.AssetEmbedder {
+	e := goembed.NewSequentialEmbedder(nil, decode, imports[:])
+	e.SetName("zdictbase64")
+	e.SetPrepare(prepare)
+	return e
+}
+
+// NewConcurrent creates a new concurrent zdictbase64embedder asset embedder.
+func NewConcurrent() goembed.AssetEmbedder {
+	e := goembed.NewConcurrentEmbedder(nil, decode, imports[:])
+	e.SetName("zdictbase64")
+	e.SetPrepare(prepare)
+	return e
+}
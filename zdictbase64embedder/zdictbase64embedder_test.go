@@ -0,0 +1,57 @@
+package zdictbase64embedder
+
+import (
+	"fmt"
+	"io/ioutil"
+	"testing"
+
+	"github.com/jeanfric/goembed"
+	"github.com/jeanfric/goembed/embedtesting"
+	"github.com/jeanfric/goembed/zbase64embedder"
+)
+
+// fragments returns a set of small, similar JSON documents.
+func fragments() map[string]string {
+	m := make(map[string]string)
+	for i := 0; i < 300; i++ {
+		m[fmt.Sprintf("/users/%d.json", i)] = fmt.Sprintf(`{"id": %d, "name": "user%d", "email": "user%d@example.com", "roles": ["reader", "writer"], "settings": {"theme": "dark", "language": "en-US", "notifications": true}}`, i, i*7, i*13)
+	}
+	return m
+}
+
+func TestEmbedder(t *testing.T) {
+	embedtesting.TestEmbedderAssets(t, NewSequential(), fragments())
+	embedtesting.TestEmbedder(t, NewConcurrent())
+}
+
+func TestSmallerThanZbase64(t *testing.T) {
+	m := fragments()
+	n, err := NewSequential().AssetEmbed(ioutil.Discard, embedtesting.AssetsFromMap(m), "main", "loadAssets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	zn, err := zbase64embedder.NewSequential().AssetEmbed(ioutil.Discard, embedtesting.AssetsFromMap(m), "main", "loadAssets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("zdictbase64: %d bytes, zbase64: %d bytes", n, zn)
+	if n >= zn {
+		t.Errorf("zdictbase64 output (%d bytes) is not smaller than zbase64 output (%d bytes)", n, zn)
+	}
+}
+
+func BenchmarkSequentialEmbedder(b *testing.B) {
+	embedtesting.BenchmarkEmbedder(b, NewSequential())
+}
+
+func BenchmarkConcurrentEmbedder(b *testing.B) {
+	embedtesting.BenchmarkEmbedder(b, NewConcurrent())
+}
+
+func TestBytesEmbedder(t *testing.T) {
+	embedtesting.TestEmbedderBytes(t, NewSequential().(goembed.ConfigurableEmbedder), goembed.Options{})
+}
+
+func TestOpenEmbedder(t *testing.T) {
+	embedtesting.TestEmbedderOpen(t, NewSequential().(goembed.ConfigurableEmbedder), goembed.Options{})
+}
@@ -1,9 +1,13 @@
 package zhexembedder
 
 import (
+	"compress/zlib"
+	"encoding/hex"
 	"testing"
 
+	"github.com/jeanfric/goembed"
 	"github.com/jeanfric/goembed/embedtesting"
+	"github.com/jeanfric/goembed/zlibcompress"
 )
 
 func BenchmarkSequentialEmbedder(b *testing.B) {
@@ -13,3 +17,37 @@ func BenchmarkSequentialEmbedder(b *testing.B) {
 func BenchmarkConcurrentEmbedder(b *testing.B) {
 	embedtesting.BenchmarkEmbedder(b, NewConcurrent())
 }
+
+func TestBytesEmbedder(t *testing.T) {
+	embedtesting.TestEmbedderBytes(t, NewSequential().(goembed.ConfigurableEmbedder), goembed.Options{})
+}
+
+func TestOpenEmbedder(t *testing.T) {
+	embedtesting.TestEmbedderOpen(t, NewSequential().(goembed.ConfigurableEmbedder), goembed.Options{})
+}
+
+// chunked returns an embedder created by newEmbedder, compressing the
+// assets in chunks of 4096 bytes.
+func chunked(newEmbedder func(*zlibcompress.Compressor) goembed.AssetEmbedder) goembed.ConfigurableEmbedder {
+	c, err := zlibcompress.New(zlib.DefaultCompression)
+	if err != nil {
+		panic(err)
+	}
+	c.SetChunkSize(4096)
+	return newEmbedder(c).(goembed.ConfigurableEmbedder)
+}
+
+func TestChunkedEmbedder(t *testing.T) {
+	embedtesting.TestEmbedder(t, chunked(NewSequentialCompressor))
+	embedtesting.TestEmbedderBytes(t, chunked(NewConcurrentCompressor), goembed.Options{})
+	embedtesting.TestEmbedderOpen(t, chunked(NewSequentialCompressor), goembed.Options{})
+}
+
+func TestDigestsEmbedder(t *testing.T) {
+	embedtesting.TestEmbedderDigests(t, NewSequential().(goembed.ConfigurableEmbedder), goembed.Options{})
+	embedtesting.TestEmbedderDigests(t, chunked(NewConcurrentCompressor), goembed.Options{Table: true})
+}
+
+func TestDecoder(t *testing.T) {
+	embedtesting.TestDecoder(t, decode, imports[:], embedtesting.ZlibDecoderTests(hex.EncodeToString))
+}
@@ -0,0 +1,312 @@
+// Package zlibcompress implements the zlib compression of assets
+// shared by the asset embedders that compress assets using zlib.
+package zlibcompress
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"path"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/jeanfric/goembed"
+)
+
+// levels lists the compression levels tried in exhaustive mode.
+// zlib.DefaultCompression is equivalent to level 6, so it is not
+// tried separately: the savings are reported against level 6.
+var levels = [...]int{
+	zlib.HuffmanOnly,
+	zlib.NoCompression,
+	1, 2, 3, 4, 5, 6, 7, 8, 9,
+}
+
+// The first byte of the data returned by Compress tells how the asset
+// is represented.  The lengths recorded in the data let decoders
+// reject data that decompresses to more bytes than the asset holds,
+// such as decompression bombs.  All integers are big-endian 32-bit
+// unsigned integers.
+const (
+	// Stored is followed by the contents of an asset stored
+	// uncompressed.
+	Stored = 0
+
+	// Chunked is followed by the header of an asset compressed
+	// in chunks, which can be decompressed independently of each
+	// other: the length of the contents of the asset, the size of
+	// the chunks (that is, the length of the decompressed
+	// contents of each chunk but the last), the number of chunks
+	// n, and the n+1 offsets in the data of the boundaries of the
+	// chunks.  Each chunk is a zlib stream.
+	Chunked = 1
+
+	// Compressed is followed by the length of the contents of an
+	// asset compressed as a single zlib stream, then by the zlib
+	// stream.
+	Compressed = 2
+)
+
+// compressedExts lists the extensions of files that are usually
+// compressed already.
+var compressedExts = map[string]bool{
+	".7z": true, ".br": true, ".bz2": true, ".gif": true,
+	".gz": true, ".jpeg": true, ".jpg": true, ".mp3": true,
+	".mp4": true, ".ogg": true, ".png": true, ".tgz": true,
+	".webm": true, ".webp": true, ".woff": true, ".woff2": true,
+	".xz": true, ".zip": true, ".zst": true,
+}
+
+// compressedMagics lists the magic numbers that begin the contents of
+// compressed file formats.
+var compressedMagics = [...]string{
+	"\x89PNG\r\n\x1a\n", // PNG
+	"\xff\xd8\xff",      // JPEG
+	"GIF87a", "GIF89a",  // GIF
+	"wOFF", "wOF2", // WOFF, WOFF2
+	"\x1f\x8b",           // gzip
+	"BZh",                // bzip2
+	"\xfd7zXZ\x00",       // xz
+	"\x28\xb5\x2f\xfd",   // Zstandard
+	"PK\x03\x04",         // zip
+	"7z\xbc\xaf\x27\x1c", // 7z
+}
+
+// IsCompressed reports whether the asset named key, with contents b,
+// is known to be compressed already, judging by its extension or by
+// the magic number its contents begin with.
+func IsCompressed(key string, b []byte) bool {
+	if compressedExts[strings.ToLower(path.Ext(key))] {
+		return true
+	}
+	for _, m := range compressedMagics {
+		if bytes.HasPrefix(b, []byte(m)) {
+			return true
+		}
+	}
+	return false
+}
+
+// A Compressor compresses assets using zlib, either at a given
+// compression level or, in exhaustive mode, at the level yielding the
+// smallest output for each asset.
+type Compressor struct {
+	level      int
+	exhaustive bool
+	chunkSize  int
+	log        io.Writer
+	logMutex   sync.Mutex
+}
+
+// New creates a new compressor that compresses assets at the given
+// level, which can be any of the levels accepted by
+// zlib.NewWriterLevel, including zlib.HuffmanOnly and
+// zlib.BestCompression.
+func New(level int) (*Compressor, error) {
+	if _, err := zlib.NewWriterLevel(ioutil.Discard, level); err != nil {
+		return nil, err
+	}
+	return &Compressor{level: level}, nil
+}
+
+// NewExhaustive creates a new compressor that compresses each asset
+// at every compression level, and keeps the smallest result.  For
+// each asset, the compressor writes to log the level it picked and
+// the number of bytes saved compared to the default level.  If log is
+// nil, nothing is written.
+func NewExhaustive(log io.Writer) *Compressor {
+	return &Compressor{
+		level:      zlib.DefaultCompression,
+		exhaustive: true,
+		log:        log,
+	}
+}
+
+// SetChunkSize makes the compressor compress the assets larger than
+// size bytes in chunks of size bytes (see Chunked), so that a part of
+// an asset can be decompressed without decompressing the parts that
+// precede it.  The chunks of an asset are compressed concurrently.
+// If size is 0, which is the default, assets are not compressed in
+// chunks.
+func (c *Compressor) SetChunkSize(size int) {
+	c.chunkSize = size
+}
+
+// Compress compresses the contents of an asset.  Assets that are
+// known to be compressed already (see IsCompressed), and assets that
+// compression does not make smaller, are not compressed: the returned
+// data is then the Stored byte followed by the contents of the asset.
+// Otherwise, the returned data is Compressed, or, for assets larger
+// than the chunk size of the compressor, Chunked.  The compressor can
+// safely be used by concurrent goroutines.
+func (c *Compressor) Compress(contents io.Reader) ([]byte, error) {
+	b, err := ioutil.ReadAll(contents)
+	if err != nil {
+		return nil, err
+	}
+	// The embedders call encoding functions with the asset being
+	// encoded.
+	key := "asset"
+	if a, ok := contents.(*goembed.Asset); ok {
+		key = a.Key
+	}
+
+	if IsCompressed(key, b) {
+		c.logf("%s: stored, %d bytes (already compressed)\n", key, len(b)+1)
+		return store(b), nil
+	}
+	if c.chunkSize > 0 && len(b) > c.chunkSize {
+		return c.compressChunks(key, b)
+	}
+	if !c.exhaustive {
+		zb, err := compress(bytes.NewReader(b), c.level)
+		if err != nil {
+			return nil, err
+		}
+		if !smaller(zb, b) {
+			return store(b), nil
+		}
+		return sized(zb, b), nil
+	}
+
+	var def, best []byte
+	bestLevel := 0
+	for _, level := range levels {
+		zb, err := compress(bytes.NewReader(b), level)
+		if err != nil {
+			return nil, err
+		}
+		if level == 6 {
+			def = zb
+		}
+		if best == nil || len(zb) < len(best) {
+			best, bestLevel = zb, level
+		}
+	}
+	if !smaller(best, b) {
+		stored := store(b)
+		c.logf("%s: stored, %d bytes (%d bytes saved)\n", key, len(stored), len(def)+5-len(stored))
+		return stored, nil
+	}
+	c.logf("%s: level %d, %d bytes (%d bytes saved)\n", key, bestLevel, len(best)+5, len(def)-len(best))
+	return sized(best, b), nil
+}
+
+// compressChunks compresses b, the contents of the asset named key,
+// in chunks, with up to runtime.NumCPU() chunks compressed
+// concurrently.  In exhaustive mode, the chunks are compressed at the
+// default level.
+func (c *Compressor) compressChunks(key string, b []byte) ([]byte, error) {
+	// The header records the length of the asset on 32 bits.
+	if int64(len(b)) > math.MaxUint32 {
+		c.logf("%s: stored, %d bytes (too large)\n", key, len(b)+1)
+		return store(b), nil
+	}
+	n := (len(b) + c.chunkSize - 1) / c.chunkSize
+	chunks := make([][]byte, n)
+	errs := make([]error, n)
+	level := c.level
+	if c.exhaustive {
+		level = zlib.DefaultCompression
+	}
+	var wg sync.WaitGroup
+	next := make(chan int)
+	for w := 0; w < runtime.NumCPU() && w < n; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				chunk := b[i*c.chunkSize:]
+				if len(chunk) > c.chunkSize {
+					chunk = chunk[:c.chunkSize]
+				}
+				chunks[i], errs[i] = compress(bytes.NewReader(chunk), level)
+			}
+		}()
+	}
+	for i := range chunks {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	header := 13 + 4*(n+1)
+	size := header
+	for _, chunk := range chunks {
+		size += len(chunk)
+	}
+	if size > len(b)+1 || int64(size) > math.MaxUint32 {
+		c.logf("%s: stored, %d bytes\n", key, len(b)+1)
+		return store(b), nil
+	}
+	zb := make([]byte, header, size)
+	zb[0] = Chunked
+	binary.BigEndian.PutUint32(zb[1:], uint32(len(b)))
+	binary.BigEndian.PutUint32(zb[5:], uint32(c.chunkSize))
+	binary.BigEndian.PutUint32(zb[9:], uint32(n))
+	for i, chunk := range chunks {
+		binary.BigEndian.PutUint32(zb[13+4*i:], uint32(len(zb)))
+		zb = append(zb, chunk...)
+	}
+	binary.BigEndian.PutUint32(zb[13+4*n:], uint32(len(zb)))
+	c.logf("%s: %d chunks, %d bytes\n", key, n, len(zb))
+	return zb, nil
+}
+
+// logf writes to the log of a compressor in exhaustive mode.
+func (c *Compressor) logf(format string, a ...interface{}) {
+	if !c.exhaustive || c.log == nil {
+		return
+	}
+	c.logMutex.Lock()
+	fmt.Fprintf(c.log, format, a...)
+	c.logMutex.Unlock()
+}
+
+// store returns the data representing b stored uncompressed.
+func store(b []byte) []byte {
+	return append([]byte{Stored}, b...)
+}
+
+// smaller reports whether the data representing b compressed as the
+// zlib stream zb is smaller than the data representing b stored
+// uncompressed.
+func smaller(zb, b []byte) bool {
+	return len(zb)+5 < len(b)+1 && int64(len(b)) <= math.MaxUint32
+}
+
+// sized returns the data representing b compressed as the zlib stream
+// zb.
+func sized(zb, b []byte) []byte {
+	d := make([]byte, 5, 5+len(zb))
+	d[0] = Compressed
+	binary.BigEndian.PutUint32(d[1:], uint32(len(b)))
+	return append(d, zb...)
+}
+
+func compress(contents io.Reader, level int) ([]byte, error) {
+	var zb bytes.Buffer
+	w, err := zlib.NewWriterLevel(&zb, level)
+	if err != nil {
+		return nil, err
+	}
+	_, err = io.Copy(w, contents)
+	if err != nil {
+		return nil, err
+	}
+	err = w.Close()
+	if err != nil {
+		return nil, err
+	}
+	return zb.Bytes(), nil
+}
@@ -0,0 +1,174 @@
+package zlibcompress
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"io/ioutil"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/jeanfric/goembed"
+	"github.com/jeanfric/goembed/embedtesting"
+)
+
+func decompress(t *testing.T, zb []byte) []byte {
+	switch zb[0] {
+	case Stored:
+		return zb[1:]
+	case Chunked:
+		var b []byte
+		n := int(binary.BigEndian.Uint32(zb[9:]))
+		for i := 0; i < n; i++ {
+			start := binary.BigEndian.Uint32(zb[13+4*i:])
+			end := binary.BigEndian.Uint32(zb[17+4*i:])
+			b = append(b, inflate(t, zb[start:end])...)
+		}
+		if len(b) != int(binary.BigEndian.Uint32(zb[1:])) {
+			t.Errorf("got %d bytes, want %d", len(b), binary.BigEndian.Uint32(zb[1:]))
+		}
+		return b
+	case Compressed:
+		b := inflate(t, zb[5:])
+		if len(b) != int(binary.BigEndian.Uint32(zb[1:])) {
+			t.Errorf("got %d bytes, want %d", len(b), binary.BigEndian.Uint32(zb[1:]))
+		}
+		return b
+	}
+	t.Fatalf("unknown format %d", zb[0])
+	return nil
+}
+
+func inflate(t *testing.T, zb []byte) []byte {
+	r, err := zlib.NewReader(bytes.NewReader(zb))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestExhaustive(t *testing.T) {
+	var log bytes.Buffer
+	c := NewExhaustive(&log)
+	def, err := New(zlib.DefaultCompression)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, a := range embedtesting.GetTestAssets() {
+		b, err := ioutil.ReadAll(a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		a.Reader = bytes.NewReader(b)
+		zb, err := c.Compress(a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := decompress(t, zb); !bytes.Equal(got, b) {
+			t.Errorf("%s: round trip mismatch", a.Key)
+		}
+		dzb, err := def.Compress(bytes.NewReader(b))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(zb) > len(dzb) {
+			t.Errorf("%s: exhaustive mode produced %d bytes, default level %d", a.Key, len(zb), len(dzb))
+		}
+		if !strings.Contains(log.String(), a.Key+": level ") && !strings.Contains(log.String(), a.Key+": stored") {
+			t.Errorf("%s: not reported in %q", a.Key, log.String())
+		}
+	}
+}
+
+func TestInvalidLevel(t *testing.T) {
+	for _, level := range []int{-3, 10} {
+		if _, err := New(level); err == nil {
+			t.Errorf("New(%d) succeeded", level)
+		}
+	}
+}
+
+func TestStored(t *testing.T) {
+	c, err := New(zlib.BestCompression)
+	if err != nil {
+		t.Fatal(err)
+	}
+	random := make([]byte, 4096)
+	rand.New(rand.NewSource(1)).Read(random)
+	text := bytes.Repeat([]byte("all work and no play makes jack a dull boy\n"), 100)
+	tests := []struct {
+		name     string
+		contents []byte
+		stored   bool
+	}{
+		{"/empty", nil, true},
+		{"/random", random, true},
+		{"/text.txt", text, false},
+		{"/text.gz", text, true},
+		{"/TEXT.PNG", text, true},
+		{"/magic", append([]byte("\x89PNG\r\n\x1a\n"), text...), true},
+	}
+	for _, tt := range tests {
+		zb, err := c.Compress(&goembed.Asset{
+			Reader: bytes.NewReader(tt.contents),
+			Key:    tt.name,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stored := zb[0] == Stored; stored != tt.stored {
+			t.Errorf("%s: stored = %v, want %v", tt.name, stored, tt.stored)
+		}
+		if got := decompress(t, zb); !bytes.Equal(got, tt.contents) {
+			t.Errorf("%s: round trip mismatch", tt.name)
+		}
+	}
+}
+
+func TestChunked(t *testing.T) {
+	c, err := New(zlib.DefaultCompression)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.SetChunkSize(1000)
+	random := make([]byte, 4096)
+	rand.New(rand.NewSource(1)).Read(random)
+	text := bytes.Repeat([]byte("all work and no play makes jack a dull boy\n"), 100)
+	tests := []struct {
+		name     string
+		contents []byte
+		chunks   int
+	}{
+		{"/small.txt", text[:1000], 0},
+		{"/text.txt", text, 5},
+		{"/exact.txt", text[:3000], 3},
+		{"/random", random, 0},
+	}
+	for _, tt := range tests {
+		zb, err := c.Compress(&goembed.Asset{
+			Reader: bytes.NewReader(tt.contents),
+			Key:    tt.name,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		chunks := 0
+		if zb[0] == Chunked {
+			if size := binary.BigEndian.Uint32(zb[5:]); size != 1000 {
+				t.Errorf("%s: chunk size = %d, want 1000", tt.name, size)
+			}
+			chunks = int(binary.BigEndian.Uint32(zb[9:]))
+		}
+		if chunks != tt.chunks {
+			t.Errorf("%s: %d chunks, want %d", tt.name, chunks, tt.chunks)
+		}
+		if got := decompress(t, zb); !bytes.Equal(got, tt.contents) {
+			t.Errorf("%s: round trip mismatch", tt.name)
+		}
+	}
+}